@@ -0,0 +1,167 @@
+// Package apierror is the one error type handlers return. Handler is the fiber ErrorHandler that
+// writes every error as {"code": ..., "message": ..., "fields": {...}} with the status matching its code
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// the machine readable codes, clients should branch on these rather than on the message
+const (
+	CodeBadRequest   = "bad_request"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+)
+
+var statuses = map[string]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeValidation:   http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeConflict:     http.StatusConflict,
+	CodeInternal:     http.StatusInternalServerError,
+}
+
+// Error an error a handler responds with. Message is shown to people, Fields maps the request fields that
+// failed validation to what is wrong with them. Err is the cause of an internal error, it is logged but never sent
+type Error struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Err     error             `json:"-"`
+
+	// status for the fiber errors whose status has no code of its own
+	status int
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status the HTTP status the error is sent with
+func (e *Error) Status() int {
+	if e.status != 0 {
+		return e.status
+	}
+
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+func BadRequest(message string) *Error {
+	return &Error{Code: CodeBadRequest, Message: message}
+}
+
+// InvalidBody the request body couldn't be decoded, or failed validation in which case the validation error is kept
+func InvalidBody(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	return &Error{Code: CodeBadRequest, Message: "invalid request body", Err: err}
+}
+
+// InvalidID a path parameter that should be an id isn't one, what names the thing it identifies
+func InvalidID(what string) *Error {
+	return &Error{Code: CodeBadRequest, Message: fmt.Sprintf("invalid %s id", what)}
+}
+
+// Validation fields maps each field that failed to what is wrong with it
+func Validation(message string, fields map[string]string) *Error {
+	return &Error{Code: CodeValidation, Message: message, Fields: fields}
+}
+
+// Field a validation error on a single field
+func Field(field, message string) *Error {
+	return Validation(message, map[string]string{field: message})
+}
+
+func Unauthorized(message string) *Error {
+	return &Error{Code: CodeUnauthorized, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+// NotFound what names the thing that doesn't exist, e.g. "booking"
+func NotFound(what string) *Error {
+	return &Error{Code: CodeNotFound, Message: what + " not found"}
+}
+
+// Conflict the request can't be done in the current state of the thing it acts on
+func Conflict(message string) *Error {
+	return &Error{Code: CodeConflict, Message: message}
+}
+
+func Internal(err error) *Error {
+	return &Error{Code: CodeInternal, Message: "something went wrong, please try again", Err: err}
+}
+
+// From turn any error into an *Error. fiber errors keep their status, gorm's not found, duplicate key
+// and foreign key errors become not found and conflicts, anything else is internal
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		for code, status := range statuses {
+			if status == fiberErr.Code && code != CodeValidation {
+				return &Error{Code: code, Message: fiberErr.Message}
+			}
+		}
+
+		// e.g. 405 becomes method_not_allowed
+		code := strings.ReplaceAll(strings.ToLower(http.StatusText(fiberErr.Code)), " ", "_")
+		return &Error{Code: code, Message: fiberErr.Message, status: fiberErr.Code}
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound("record")
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Code: CodeConflict, Message: "a record with the same unique value already exists", Err: err}
+	case errors.Is(err, gorm.ErrForeignKeyViolated):
+		return &Error{Code: CodeConflict, Message: "the record is referenced by or references another record", Err: err}
+	}
+
+	return Internal(err)
+}
+
+// Handler the fiber ErrorHandler, internal errors are logged with their cause
+func Handler(c fiber.Ctx, err error) error {
+	apiErr := From(err)
+
+	if apiErr.Code == CodeInternal {
+		log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+	}
+
+	return c.Status(apiErr.Status()).JSON(apiErr)
+}
@@ -0,0 +1,252 @@
+// Package audit records who changed what. Every mutation writes an Entry with the user who made it, what they did
+// and the fields of the entity that changed, before and after, in the same transaction as the change itself so
+// there is never a change without its entry or an entry for a change that was rolled back
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/hidenkeys/timeless/pagination"
+	"gorm.io/gorm"
+)
+
+// the kinds of entity that are audited
+const (
+	EntityBooking     = "booking"
+	EntityRoomBooking = "room_booking"
+	EntityPayment     = "payment"
+	EntityCharge      = "charge"
+	EntityRoom        = "room"
+	EntityRatePlan    = "rate_plan"
+	EntityCustomer    = "customer"
+	EntityUser        = "user"
+
+	EntityHousekeepingTask = "housekeeping_task"
+	EntityMaintenanceBlock = "maintenance_block"
+)
+
+// Entities every kind of entity that is audited
+var Entities = []string{
+	EntityBooking, EntityRoomBooking, EntityPayment, EntityCharge,
+	EntityRoom, EntityRatePlan, EntityCustomer, EntityUser,
+	EntityHousekeepingTask, EntityMaintenanceBlock,
+}
+
+// what was done to an entity
+const (
+	ActionCreate         = "create"
+	ActionUpdate         = "update"
+	ActionDelete         = "delete"
+	ActionCancel         = "cancel"
+	ActionCheckIn        = "check_in"
+	ActionCheckOut       = "check_out"
+	ActionExtend         = "extend"
+	ActionMarkPaid       = "mark_paid"
+	ActionVoid           = "void"
+	ActionChangePassword = "change_password"
+	ActionChangeStatus   = "change_status"
+	ActionClaim          = "claim"
+	ActionComplete       = "complete"
+	ActionClose          = "close"
+	ActionMove           = "move"
+	ActionNoShow         = "no_show"
+)
+
+// Actions everything that can be done to an entity
+var Actions = []string{
+	ActionCreate, ActionUpdate, ActionDelete, ActionCancel, ActionCheckIn, ActionCheckOut,
+	ActionExtend, ActionMarkPaid, ActionVoid, ActionChangePassword, ActionChangeStatus, ActionClaim, ActionComplete,
+	ActionClose, ActionMove, ActionNoShow,
+}
+
+// ignored fields that change on every update and say nothing about what was done
+var ignored = []string{"UpdatedAt"}
+
+// Entry one change to an entity. Before and After hold the fields that changed, a created entity has no Before
+// and a deleted one no After. ActorID is nil for changes made by the system rather than a signed in user
+type Entry struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+	ActorID    *uint     `json:"actorID" gorm:"index"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entityType" gorm:"index:idx_audit_entries_entity"`
+	EntityID   uint      `json:"entityID" gorm:"index:idx_audit_entries_entity"`
+	Before     JSON      `json:"before"`
+	After      JSON      `json:"after"`
+}
+
+func (Entry) TableName() string { return "audit_entries" }
+
+// JSON a json document stored as text, written out as is rather than as a string
+type JSON string
+
+func (j JSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+
+	return []byte(j), nil
+}
+
+// Filter the audit query parameters, an empty field doesn't filter. To is exclusive
+type Filter struct {
+	Entity   string
+	EntityID uint
+	ActorID  uint
+	Action   string
+	From     time.Time
+	To       time.Time
+}
+
+// Sorts what the audit log can be sorted by
+var Sorts = pagination.Sorts{
+	"id":        "id",
+	"createdAt": "created_at",
+}
+
+// Log the audit log
+type Log interface {
+	// Record write down that actor did action to the entity with id entityID. before and after are the entity as it
+	// was and as it is, nil for the side that doesn't exist; only the fields that differ are kept
+	Record(actor uint, action, entity string, entityID uint, before, after any) error
+	// List one page of the entries matching filter and how many match in all
+	List(filter Filter, page pagination.Params) ([]Entry, int64, error)
+}
+
+// NewLog a Log backed by db, give it the transaction of the change being recorded
+func NewLog(db *gorm.DB) Log {
+	return gormLog{db: db}
+}
+
+type gormLog struct {
+	db *gorm.DB
+}
+
+func (l gormLog) Record(actor uint, action, entity string, entityID uint, before, after any) error {
+	beforeJSON, afterJSON, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	entry := Entry{
+		CreatedAt:  time.Now().UTC(),
+		Action:     action,
+		EntityType: entity,
+		EntityID:   entityID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+	}
+
+	if actor != 0 {
+		entry.ActorID = &actor
+	}
+
+	return l.db.Create(&entry).Error
+}
+
+func (l gormLog) List(filter Filter, page pagination.Params) ([]Entry, int64, error) {
+	query := l.db.Model(&Entry{})
+
+	if filter.Entity != "" {
+		query = query.Where("entity_type = ?", filter.Entity)
+	}
+
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From.UTC())
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.UTC())
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var entries []Entry
+	if result := query.Scopes(page.Scope).Find(&entries); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return entries, total, nil
+}
+
+// diff the fields of before and after that differ. when one side is nil the other is kept whole
+func diff(before, after any) (JSON, JSON, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return "", "", err
+	}
+
+	afterFields, err := fields(after)
+	if err != nil {
+		return "", "", err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for _, name := range ignored {
+			delete(beforeFields, name)
+			delete(afterFields, name)
+		}
+
+		for name, value := range beforeFields {
+			if other, ok := afterFields[name]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, name)
+				delete(afterFields, name)
+			}
+		}
+	}
+
+	beforeJSON, err := encode(beforeFields)
+	if err != nil {
+		return "", "", err
+	}
+
+	afterJSON, err := encode(afterFields)
+	return beforeJSON, afterJSON, err
+}
+
+// fields an entity as its json fields, nil for a nil entity
+func fields(entity any) (map[string]any, error) {
+	if entity == nil {
+		return nil, nil
+	}
+
+	if v := reflect.ValueOf(entity); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]any
+	return m, json.Unmarshal(b, &m)
+}
+
+func encode(m map[string]any) (JSON, error) {
+	if m == nil {
+		return "", nil
+	}
+
+	b, err := json.Marshal(m)
+	return JSON(b), err
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/pagination"
+)
+
+// Handler the audit log endpoints
+type Handler struct {
+	log Log
+}
+
+func NewHandler(log Log) *Handler {
+	return &Handler{log: log}
+}
+
+// List {params: [entity, entityId, actor, action, from, to, page, page_size, sort, order]}
+// the changes made to entity (one of Entities) by the user actor between from and to (YYYY-MM-DD, both included),
+// newest first by default
+func (h *Handler) List(c fiber.Ctx) error {
+	filter := Filter{
+		Entity: c.Query("entity"),
+		Action: c.Query("action"),
+	}
+
+	if filter.Entity != "" && !slices.Contains(Entities, filter.Entity) {
+		return apierror.Field("entity", "entity must be one of "+strings.Join(Entities, ", "))
+	}
+
+	if filter.Action != "" && !slices.Contains(Actions, filter.Action) {
+		return apierror.Field("action", "action must be one of "+strings.Join(Actions, ", "))
+	}
+
+	var err error
+	if filter.EntityID, err = idQuery(c, "entityId"); err != nil {
+		return err
+	}
+
+	if filter.ActorID, err = idQuery(c, "actor"); err != nil {
+		return err
+	}
+
+	if from := c.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.DateOnly, from); err != nil {
+			return apierror.Field("from", "invalid from date, expected YYYY-MM-DD")
+		}
+	}
+
+	if to := c.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.DateOnly, to); err != nil {
+			return apierror.Field("to", "invalid to date, expected YYYY-MM-DD")
+		}
+
+		// the whole of the last day is included
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return apierror.Field("to", "to must not be before from")
+	}
+
+	page, err := pagination.FromQuery(c, Sorts, "createdAt", "desc")
+	if err != nil {
+		return err
+	}
+
+	entries, total, err := h.log.List(filter, page)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(pagination.New(entries, total, page))
+}
+
+// idQuery read an id query parameter, 0 when it isn't given
+func idQuery(c fiber.Ctx, param string) (uint, error) {
+	value := c.Query(param)
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 0)
+	if err != nil || id == 0 {
+		return 0, apierror.Field(param, param+" must be an id")
+	}
+
+	return uint(id), nil
+}
@@ -0,0 +1,283 @@
+// Package config loads the settings of a deployment from a YAML or TOML file and TIMELESS_* environment variables.
+// both formats have the same keys, e.g. accessTokenTTL or database.driver
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hidenkeys/timeless/storage"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvStaging     = "staging"
+	EnvProduction  = "production"
+)
+
+// DefaultJWTSecret the secret development runs with, every other env refuses to start with it
+const DefaultJWTSecret = "secret"
+
+// Config everything that changes between deployments
+type Config struct {
+	// Env one of development, staging or production
+	Env  string `yaml:"env"`
+	Port string `yaml:"port"`
+
+	Database Database `yaml:"database"`
+
+	JWTSecret       string        `yaml:"jwtSecret"`
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
+
+	// AdminPassword the password of the admin account created on the first start, without it one is generated and
+	// only shown when the first start is on a terminal
+	AdminPassword string `yaml:"adminPassword"`
+
+	// CORSOrigins origins the frontend is served from
+	CORSOrigins []string `yaml:"corsOrigins"`
+
+	Cancellation Cancellation `yaml:"cancellation"`
+
+	Hotel Hotel `yaml:"hotel"`
+}
+
+type Database struct {
+	// Driver one of sqlite, postgres or mysql
+	Driver string `yaml:"driver"`
+	// DSN the connection string, for sqlite it defaults to Path
+	DSN string `yaml:"dsn"`
+	// Path of the sqlite database file
+	Path string `yaml:"path"`
+}
+
+// Cancellation the cancellation policy bookings are cancelled under
+type Cancellation struct {
+	// FreeWindow cancelling at least this long before a stay starts costs nothing
+	FreeWindow time.Duration `yaml:"freeWindow"`
+	// PenaltyNights nights of each stay charged when cancelling inside the free window
+	PenaltyNights uint `yaml:"penaltyNights"`
+}
+
+// Hotel the details printed at the top of every invoice
+type Hotel struct {
+	Name    string `yaml:"name"`
+	Address string `yaml:"address"`
+	Phone   string `yaml:"phone"`
+	Email   string `yaml:"email"`
+	// Currency the code amounts are in e.g. NGN
+	Currency string `yaml:"currency"`
+}
+
+// Source the connection string to hand to the driver
+func (d Database) Source() string {
+	if d.DSN == "" && d.Driver == storage.SQLite {
+		return d.Path
+	}
+
+	return d.DSN
+}
+
+// Default the settings used for anything the file and environment leave out
+func Default() Config {
+	return Config{
+		Env:             EnvDevelopment,
+		Port:            "3000",
+		Database:        Database{Driver: storage.SQLite, Path: "./timeless.db"},
+		JWTSecret:       DefaultJWTSecret,
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 30 * 24 * time.Hour,
+		CORSOrigins:     []string{"http://localhost:5173"},
+		Cancellation:    Cancellation{FreeWindow: 48 * time.Hour, PenaltyNights: 1},
+		Hotel:           Hotel{Name: "Timeless Hotel", Currency: "NGN"},
+	}
+}
+
+// Load read the config, later sources override earlier ones: defaults, the yaml or toml file named by
+// TIMELESS_CONFIG (./timeless.yaml or ./timeless.toml when one exists), then TIMELESS_* environment variables
+func Load() (Config, error) {
+	cfg := Default()
+
+	path, explicit := os.LookupEnv("TIMELESS_CONFIG")
+	if !explicit {
+		path = "timeless.yaml"
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			path = "timeless.toml"
+		}
+	}
+
+	if err := cfg.loadFile(path, explicit); err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (cfg *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		if data, err = tomlToYAML(data); err != nil {
+			return fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// tomlToYAML the same settings as yaml, so a toml file is decoded by the same keys and durations like 15m are read
+// the same way as in a yaml file
+func tomlToYAML(data []byte) ([]byte, error) {
+	var values map[string]any
+	if err := toml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(values)
+}
+
+func (cfg *Config) loadEnv() error {
+	strs := map[string]*string{
+		"TIMELESS_ENV":            &cfg.Env,
+		"TIMELESS_PORT":           &cfg.Port,
+		"TIMELESS_DB_DRIVER":      &cfg.Database.Driver,
+		"TIMELESS_DB_DSN":         &cfg.Database.DSN,
+		"TIMELESS_DB_PATH":        &cfg.Database.Path,
+		"TIMELESS_JWT_SECRET":     &cfg.JWTSecret,
+		"TIMELESS_ADMIN_PASSWORD": &cfg.AdminPassword,
+		"TIMELESS_HOTEL_NAME":     &cfg.Hotel.Name,
+		"TIMELESS_HOTEL_ADDRESS":  &cfg.Hotel.Address,
+		"TIMELESS_HOTEL_PHONE":    &cfg.Hotel.Phone,
+		"TIMELESS_HOTEL_EMAIL":    &cfg.Hotel.Email,
+		"TIMELESS_HOTEL_CURRENCY": &cfg.Hotel.Currency,
+	}
+	for name, field := range strs {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"TIMELESS_ACCESS_TOKEN_TTL":         &cfg.AccessTokenTTL,
+		"TIMELESS_REFRESH_TOKEN_TTL":        &cfg.RefreshTokenTTL,
+		"TIMELESS_CANCELLATION_FREE_WINDOW": &cfg.Cancellation.FreeWindow,
+	}
+	for name, field := range durations {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*field = d
+	}
+
+	if value, ok := os.LookupEnv("TIMELESS_CANCELLATION_PENALTY_NIGHTS"); ok {
+		nights, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return fmt.Errorf("TIMELESS_CANCELLATION_PENALTY_NIGHTS: %w", err)
+		}
+		cfg.Cancellation.PenaltyNights = uint(nights)
+	}
+
+	if value, ok := os.LookupEnv("TIMELESS_CORS_ORIGINS"); ok {
+		cfg.CORSOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate check the config can be started with, every problem is reported at once
+func (cfg Config) Validate() error {
+	var errs []error
+
+	if !slices.Contains([]string{EnvDevelopment, EnvStaging, EnvProduction}, cfg.Env) {
+		errs = append(errs, fmt.Errorf("env must be one of %s, %s or %s, got %q", EnvDevelopment, EnvStaging, EnvProduction, cfg.Env))
+	}
+
+	if cfg.Port == "" {
+		errs = append(errs, errors.New("port is required"))
+	}
+
+	if !slices.Contains([]string{storage.SQLite, storage.Postgres, storage.MySQL}, cfg.Database.Driver) {
+		errs = append(errs, fmt.Errorf("database driver must be one of %s, %s or %s, got %q", storage.SQLite, storage.Postgres, storage.MySQL, cfg.Database.Driver))
+	} else if cfg.Database.Source() == "" {
+		errs = append(errs, fmt.Errorf("a database dsn is required for %s", cfg.Database.Driver))
+	}
+
+	switch {
+	case cfg.JWTSecret == "":
+		errs = append(errs, errors.New("jwt secret is required"))
+	case cfg.Env != EnvDevelopment && cfg.JWTSecret == DefaultJWTSecret:
+		errs = append(errs, fmt.Errorf("refusing to run in %s with the default jwt secret, set TIMELESS_JWT_SECRET", cfg.Env))
+	case cfg.Env == EnvProduction && len(cfg.JWTSecret) < 32:
+		errs = append(errs, errors.New("jwt secret must be at least 32 characters in production"))
+	}
+
+	if cfg.AccessTokenTTL <= 0 || cfg.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("token ttls must be positive"))
+	} else if cfg.AccessTokenTTL >= cfg.RefreshTokenTTL {
+		errs = append(errs, errors.New("access token ttl must be shorter than the refresh token ttl"))
+	}
+
+	if len(cfg.CORSOrigins) == 0 {
+		errs = append(errs, errors.New("at least one cors origin is required"))
+	}
+	for _, origin := range cfg.CORSOrigins {
+		if origin == "*" {
+			errs = append(errs, errors.New("cors origin * can't be used, auth relies on credentials"))
+		}
+	}
+
+	if cfg.Hotel.Name == "" || cfg.Hotel.Currency == "" {
+		errs = append(errs, errors.New("the hotel name and currency are required, they are printed on every invoice"))
+	}
+
+	if cfg.Cancellation.FreeWindow < 0 {
+		errs = append(errs, errors.New("cancellation free window can't be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Addr the address to listen on
+func (cfg Config) Addr() string {
+	if strings.Contains(cfg.Port, ":") {
+		return cfg.Port
+	}
+
+	return ":" + cfg.Port
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load the config read from a file holding content with the given extension
+func load(t *testing.T, ext, content string) (Config, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "timeless"+ext)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TIMELESS_CONFIG", path)
+	return Load()
+}
+
+// TestLoadTOML a toml file sets the same keys a yaml file does
+func TestLoadTOML(t *testing.T) {
+	fromYAML, err := load(t, ".yaml", `
+port: "8080"
+accessTokenTTL: 10m
+corsOrigins: [https://a.example, https://b.example]
+database:
+  driver: postgres
+  dsn: postgres://localhost/timeless
+cancellation:
+  freeWindow: 24h
+  penaltyNights: 2
+hotel:
+  name: Seaside
+  currency: USD
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromTOML, err := load(t, ".toml", `
+port = "8080"
+accessTokenTTL = "10m"
+corsOrigins = ["https://a.example", "https://b.example"]
+
+[database]
+driver = "postgres"
+dsn = "postgres://localhost/timeless"
+
+[cancellation]
+freeWindow = "24h"
+penaltyNights = 2
+
+[hotel]
+name = "Seaside"
+currency = "USD"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromTOML, fromYAML) {
+		t.Errorf("toml config %+v, want %+v", fromTOML, fromYAML)
+	}
+
+	if fromTOML.AccessTokenTTL != 10*time.Minute || fromTOML.Cancellation.PenaltyNights != 2 || fromTOML.Hotel.Name != "Seaside" {
+		t.Errorf("toml config %+v doesn't have the values of the file", fromTOML)
+	}
+
+	if _, err = load(t, ".toml", `jwtSecrte = "typo"`); err == nil || !strings.Contains(err.Error(), "jwtSecrte") {
+		t.Errorf("an unknown toml key gave %v, want it refused", err)
+	}
+}
+
+// TestLoadExamples the example files load and agree with each other
+func TestLoadExamples(t *testing.T) {
+	t.Setenv("TIMELESS_CONFIG", "../timeless.example.yaml")
+	fromYAML, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("TIMELESS_CONFIG", "../timeless.example.toml")
+	fromTOML, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromTOML, fromYAML) {
+		t.Errorf("timeless.example.toml %+v, timeless.example.yaml %+v", fromTOML, fromYAML)
+	}
+}
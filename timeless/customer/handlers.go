@@ -1,129 +1,188 @@
 package customer
 
 import (
-	"database/sql"
-	"errors"
-	"fmt"
 	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/rbac"
 	"github.com/hidenkeys/timeless/room"
-	"github.com/hidenkeys/timeless/storage"
-	"gorm.io/gorm"
+	"github.com/hidenkeys/timeless/validation"
 	"net/http"
 	"strconv"
 )
 
-func Create(c fiber.Ctx) error {
+// Handler the customer endpoints
+type Handler struct {
+	customers Repository
+	bookings  room.BookingRepository
+}
+
+func NewHandler(customers Repository, bookings room.BookingRepository) *Handler {
+	return &Handler{customers: customers, bookings: bookings}
+}
+
+func (h *Handler) Create(c fiber.Ctx) error {
 	newCustomer := new(Customer)
 
 	if err := c.Bind().JSON(newCustomer); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
 	}
 
-	if result := storage.DB.Create(newCustomer); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err := h.customers.Transaction(func(tx Repository) error {
+		if err := tx.Create(newCustomer); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionCreate, audit.EntityCustomer, newCustomer.ID, nil, newCustomer)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(newCustomer)
 }
 
-func Update(c fiber.Ctx) error {
+func (h *Handler) Update(c fiber.Ctx) error {
 	newCustomerInfo := new(Customer)
 	customerId, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fmt.Errorf("invalid customer id"))
+		return apierror.InvalidID("customer")
 	}
 
-	if err = c.Bind().JSON(&newCustomerInfo); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+	if err = c.Bind().JSON(&validation.Partial{Value: newCustomerInfo}); err != nil {
+		return apierror.InvalidBody(err)
 	}
 
-	customer := new(Customer)
-	customer.ID = uint(customerId)
+	err = h.customers.Transaction(func(tx Repository) error {
+		before, err := tx.Find(uint(customerId))
+		if err != nil {
+			return err
+		}
+
+		if before.ID == 0 {
+			return apierror.NotFound("customer")
+		}
 
-	if result := storage.DB.Model(customer).Updates(newCustomerInfo); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+		if err = tx.Update(before.ID, newCustomerInfo); err != nil {
+			return err
+		}
+
+		after, err := tx.Find(before.ID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionUpdate, audit.EntityCustomer, before.ID, before, after)
+	})
+	if err != nil {
+		return err
 	}
 
+	customer := new(Customer)
+	customer.ID = uint(customerId)
+
 	return c.Status(http.StatusOK).JSON(customer)
 }
 
-// FindByName find customer by search {param: [name]}
-func FindByName(c fiber.Ctx) error {
-	name := c.Query("name", "")
-
-	var customers []Customer
-
-	nameWildCard := "%" + name + "%"
+// FindByName find customer by search {param: [name, page, page_size, sort, order]}
+// name can be several words e.g. "john doe", the best matches come first unless sort is given
+func (h *Handler) FindByName(c fiber.Ctx) error {
+	page, err := pagination.FromQuery(c, Sorts, "", "asc")
+	if err != nil {
+		return err
+	}
 
-	if result := storage.DB.Raw("SELECT * FROM customers WHERE firstName LIKE @name OR lastName LIKE @name or email like @name or phone like @name or plateNumber like @name", sql.Named("name", nameWildCard)).Find(&customers); result.Error != nil {
-		if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return c.Status(http.StatusInternalServerError).JSON(result.Error)
-		}
+	customers, total, err := h.customers.Search(c.Query("name", ""), page)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(customers)
+	return c.Status(http.StatusOK).JSON(pagination.New(customers, total, page))
 }
 
-func Delete(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) Delete(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	if err != nil {
+		return apierror.InvalidID("customer")
 	}
 
-	if result := storage.DB.Exec("DELETE FROM customers WHERE id == ?", id); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err = h.customers.Transaction(func(tx Repository) error {
+		customer, err := tx.Find(uint(id))
+		if err != nil {
+			return err
+		}
+
+		if customer.ID == 0 {
+			return apierror.NotFound("customer")
+		}
+
+		if err = tx.Delete(customer.ID); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionDelete, audit.EntityCustomer, customer.ID, customer, nil)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(http.StatusNoContent)
 }
 
-func GetBookings(c fiber.Ctx) error {
-	//limit := c.Query("page_size", "10")
-	//offset := c.Query("page", "0")
-	id := c.Params("id")
+// GetBookings {params: [page, page_size, sort, order]} the bookings of a customer, newest first by default
+func (h *Handler) GetBookings(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	if err != nil {
+		return apierror.InvalidID("customer")
+	}
+
+	page, err := pagination.FromQuery(c, room.BookingSorts, "createdAt", "desc")
+	if err != nil {
+		return err
 	}
 
-	var bookings []room.Booking
-	if result := storage.DB.Preload("RoomBookings").Raw("SELECT * FROM bookings WHERE customer_id == ? ", id).Find(&bookings); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	bookings, total, err := h.bookings.ForCustomer(uint(id), page)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(bookings)
+	return c.Status(http.StatusOK).JSON(pagination.New(bookings, total, page))
 }
 
-func GetById(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) GetById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	if err != nil {
+		return apierror.InvalidID("customer")
 	}
 
-	var customer Customer
-
-	if result := storage.DB.Raw("SELECT * FROM customers WHERE id == ?", id).Scan(&customer); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	customer, err := h.customers.Find(uint(id))
+	if err != nil {
+		return err
 	}
 
 	if customer.ID == 0 {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+		return apierror.NotFound("customer")
 	}
 
 	return c.Status(http.StatusOK).JSON(customer)
 }
 
-func GetAll(c fiber.Ctx) error {
-	//limit := c.Query("page_size", "10")
-	//offset := c.Query("page", "0")
-	var customers []Customer
+// GetAll {params: [page, page_size, sort, order]}
+func (h *Handler) GetAll(c fiber.Ctx) error {
+	page, err := pagination.FromQuery(c, Sorts, "id", "asc")
+	if err != nil {
+		return err
+	}
 
-	if result := storage.DB.Raw("SELECT * FROM customers").Find(&customers); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	customers, total, err := h.customers.List(page)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(customers)
+	return c.Status(http.StatusOK).JSON(pagination.New(customers, total, page))
 }
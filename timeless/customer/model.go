@@ -9,7 +9,7 @@ type Customer struct {
 	gorm.Model
 	FirstName        *string        `json:"firstName" validate:"required"`
 	LastName         *string        `json:"lastName" validate:"required"`
-	Phone            *string        `json:"phone" validate:"required"`
+	Phone            *string        `json:"phone" validate:"required,phone"`
 	Address          *string        `json:"address"`
 	EmergencyContact *string        `json:"emergencyContact"`
 	Email            *string        `json:"email" validate:"required,email"`
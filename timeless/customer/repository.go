@@ -0,0 +1,114 @@
+package customer
+
+import (
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/search"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository customers, a customer that doesn't exist comes back with ID 0.
+// Transaction runs fn with a Repository bound to one database transaction
+type Repository interface {
+	Find(id uint) (Customer, error)
+	// List one page of the customers and how many there are in all
+	List(page pagination.Params) ([]Customer, int64, error)
+	// Search one page of the customers with every word of q in their name, email, phone or plate number,
+	// best match first unless the page is sorted
+	Search(q string, page pagination.Params) ([]Customer, int64, error)
+	Create(customer *Customer) error
+	Update(id uint, customer *Customer) error
+	Delete(id uint) error
+	// Audit the audit log, changes made in a transaction are recorded through the transaction's Repository
+	Audit() audit.Log
+
+	Transaction(fn func(Repository) error) error
+}
+
+// Sorts what the customer lists can be sorted by, qualified as search joins a table with the same columns
+var Sorts = pagination.Sorts{
+	"id":        "customers.id",
+	"firstName": "customers.first_name",
+	"lastName":  "customers.last_name",
+	"email":     "customers.email",
+	"createdAt": "customers.created_at",
+}
+
+// NewRepository a Repository backed by db
+func NewRepository(db *gorm.DB) Repository {
+	return repository{
+		db:    db,
+		index: search.NewIndex(db, "customers", "first_name", "last_name", "email", "phone", "plate_number"),
+	}
+}
+
+type repository struct {
+	db    *gorm.DB
+	index search.Index
+}
+
+func (r repository) Find(id uint) (Customer, error) {
+	var customer Customer
+	return customer, r.db.Raw("SELECT * FROM customers WHERE id = ?", id).Scan(&customer).Error
+}
+
+func (r repository) List(page pagination.Params) ([]Customer, int64, error) {
+	return r.page(r.db.Model(&Customer{}), page, nil)
+}
+
+func (r repository) Search(q string, page pagination.Params) ([]Customer, int64, error) {
+	terms := search.Terms(q)
+	if len(terms) == 0 {
+		return r.List(page)
+	}
+
+	query, rank := r.index.Match(r.db.Model(&Customer{}), terms)
+	return r.page(query, page, rank)
+}
+
+// page count the customers query matches and load one page of them, ordered by rank when the page isn't sorted
+func (r repository) page(query *gorm.DB, page pagination.Params, rank clause.Expression) ([]Customer, int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	// the rank is the whole order, it ties by id like the page's order does. gorm's Order can't take it
+	if rank != nil && page.Sort == "" {
+		query = query.Clauses(rank).Scopes(page.Window)
+	} else {
+		query = query.Scopes(page.Scope)
+	}
+
+	var customers []Customer
+	if result := query.Find(&customers); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return customers, total, nil
+}
+
+func (r repository) Create(customer *Customer) error {
+	return r.db.Create(customer).Error
+}
+
+func (r repository) Update(id uint, customer *Customer) error {
+	return r.db.Model(&Customer{Model: gorm.Model{ID: id}}).Updates(customer).Error
+}
+
+func (r repository) Delete(id uint) error {
+	return r.db.Exec("DELETE FROM customers WHERE id = ?", id).Error
+}
+
+func (r repository) Audit() audit.Log {
+	return audit.NewLog(r.db)
+}
+
+func (r repository) Transaction(fn func(Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(repository{db: tx, index: r.index})
+	})
+}
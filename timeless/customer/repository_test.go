@@ -0,0 +1,137 @@
+package customer
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"gorm.io/gorm"
+)
+
+// newCustomer a customer with the given names and email
+func newCustomer(firstName, lastName, email string) *Customer {
+	phone, plate := "08012345678", "LAG-123"
+	return &Customer{FirstName: &firstName, LastName: &lastName, Email: &email, Phone: &phone, PlateNumber: &plate}
+}
+
+// searchNames the full names of the customers matching q, best match first
+func searchNames(t *testing.T, customers Repository, q string) []string {
+	t.Helper()
+
+	found, total, err := customers.Search(q, pagination.Params{Page: 1, PageSize: 10, Order: "asc"})
+	if err != nil {
+		t.Fatalf("search %q: %v", q, err)
+	}
+
+	names := make([]string, 0, len(found))
+	for _, customer := range found {
+		names = append(names, *customer.FirstName+" "+*customer.LastName)
+	}
+
+	if int(total) != len(names) {
+		t.Errorf("search %q: total %d, want %d", q, total, len(names))
+	}
+
+	return names
+}
+
+// withoutFullText drop the customers' FTS5 index, search then falls back to LIKE as it does without FTS5
+func withoutFullText(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	for _, statement := range []string{
+		"DROP TRIGGER IF EXISTS customers_fts_insert",
+		"DROP TRIGGER IF EXISTS customers_fts_delete",
+		"DROP TRIGGER IF EXISTS customers_fts_update",
+		"DROP TABLE IF EXISTS customers_fts",
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestSearch search ranks, needs every word and follows changes to the customers, through the FTS5 index when sqlite
+// has it (go test -tags sqlite_fts5, see the Makefile) and through LIKE
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		fullText bool
+	}{
+		{"fts5", true},
+		{"like", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := storagetest.New(t)
+			if !test.fullText {
+				withoutFullText(t, db)
+			}
+
+			customers := NewRepository(db)
+			if fullText := customers.(repository).index.FullText(); fullText != test.fullText {
+				if test.fullText {
+					t.Skip("sqlite is built without FTS5, run the tests with -tags sqlite_fts5 (make test)")
+				}
+				t.Fatalf("searching with full text %t, want %t", fullText, test.fullText)
+			}
+
+			storagetest.Create(t, db,
+				newCustomer("Johnny", "Appleseed", "apple@seed.com"),
+				newCustomer("John", "Doe", "john@doe.com"),
+				newCustomer("Jane", "Doe", "jane@example.com"),
+			)
+
+			searches := []struct {
+				q    string
+				want []string
+			}{
+				// john is the whole of John Doe's first name and starts his email, Johnny only starts with it
+				{"john", []string{"John Doe", "Johnny Appleseed"}},
+				{"john doe", []string{"John Doe"}},
+				{"DOE  John", []string{"John Doe"}},
+				{`"jane" doe`, []string{"Jane Doe"}},
+				{"john smith", []string{}},
+			}
+
+			for _, search := range searches {
+				if got := searchNames(t, customers, search.q); !slices.Equal(got, search.want) {
+					t.Errorf("search %q: %v, want %v", search.q, got, search.want)
+				}
+			}
+
+			// the index follows customers being added, changed and removed
+			added := newCustomer("Zed", "Quill", "zed@example.com")
+			if err := customers.Create(added); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := searchNames(t, customers, "quill"); !slices.Equal(got, []string{"Zed Quill"}) {
+				t.Errorf("search for a new customer: %v, want [Zed Quill]", got)
+			}
+
+			lastName := "Quincy"
+			if err := customers.Update(added.ID, &Customer{LastName: &lastName}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := searchNames(t, customers, "quill"); len(got) != 0 {
+				t.Errorf("search for an old name: %v, want none", got)
+			}
+
+			if got := searchNames(t, customers, "quincy"); !slices.Equal(got, []string{"Zed Quincy"}) {
+				t.Errorf("search for a changed name: %v, want [Zed Quincy]", got)
+			}
+
+			if err := customers.Delete(added.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := searchNames(t, customers, "quincy"); len(got) != 0 {
+				t.Errorf("search for a deleted customer: %v, want none", got)
+			}
+		})
+	}
+}
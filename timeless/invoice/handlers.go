@@ -0,0 +1,121 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/customer"
+	"github.com/hidenkeys/timeless/room"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Handler the invoice endpoints
+type Handler struct {
+	db        *gorm.DB
+	rooms     room.Store
+	customers customer.Repository
+	hotel     HotelDetails
+}
+
+func NewHandler(db *gorm.DB, rooms room.Store, customers customer.Repository, hotel HotelDetails) *Handler {
+	return &Handler{db: db, rooms: rooms, customers: customers, hotel: hotel}
+}
+
+// GetInvoice render the invoice of a booking as a PDF, the invoice number is issued the first time it is requested
+func (h *Handler) GetInvoice(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	booking, err := h.rooms.Bookings().FindFolio(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if booking.ID == 0 {
+		return apierror.NotFound("booking")
+	}
+
+	var guest customer.Customer
+	if booking.CustomerID != nil {
+		if guest, err = h.customers.Find(*booking.CustomerID); err != nil {
+			return err
+		}
+	}
+
+	roomIDs := make([]uint, 0, len(booking.RoomBookings))
+	for _, roomBooking := range booking.RoomBookings {
+		roomIDs = append(roomIDs, roomBooking.RoomID)
+	}
+
+	rooms, err := h.rooms.Rooms().FindMany(roomIDs)
+	if err != nil {
+		return err
+	}
+
+	inv, err := issue(h.db, booking.ID)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = render(&buf, h.hotel, inv, booking, guest, rooms); err != nil {
+		return apierror.Internal(err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", inv.Code()+".pdf"))
+
+	return c.Status(http.StatusOK).Send(buf.Bytes())
+}
+
+// issue get the invoice of a booking, taking the next number from the sequence if it has none yet. the sequence
+// row is seeded by migration 10 and stays locked until the invoice is saved, so a failed insert gives the number back
+// and two requests for the same booking issue it once
+func issue(db *gorm.DB, bookingID uint) (Invoice, error) {
+	var inv Invoice
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Where("booking_id = ?", bookingID).Find(&inv); result.Error != nil || inv.ID != 0 {
+			return result.Error
+		}
+
+		var sequence InvoiceSequence
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", 1).Find(&sequence); result.Error != nil {
+			return result.Error
+		}
+
+		if sequence.ID == 0 {
+			return errors.New("the invoice sequence is missing, run the migrations")
+		}
+
+		// another request for the same booking may have issued it while this one waited for the lock. a locking read
+		// sees it even where the transaction's snapshot is older (mysql)
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id = ?", bookingID).Find(&inv); result.Error != nil || inv.ID != 0 {
+			return result.Error
+		}
+
+		sequence.Last++
+		if result := tx.Save(&sequence); result.Error != nil {
+			return result.Error
+		}
+
+		inv = Invoice{
+			BookingID: bookingID,
+			Number:    sequence.Last,
+			IssuedAt:  time.Now().UTC(),
+		}
+
+		return tx.Create(&inv).Error
+	})
+
+	return inv, err
+}
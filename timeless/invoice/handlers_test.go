@@ -0,0 +1,109 @@
+package invoice
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"gorm.io/gorm"
+)
+
+// TestMain stop the database servers ForEachDialect started
+func TestMain(m *testing.M) {
+	storagetest.Main(m)
+}
+
+// issueAtOnce issue the invoice of every booking in bookingIDs at the same time, the invoices are in the same order
+func issueAtOnce(t *testing.T, db *gorm.DB, bookingIDs ...uint) []Invoice {
+	t.Helper()
+
+	invoices := make([]Invoice, len(bookingIDs))
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i, bookingID := range bookingIDs {
+		wg.Add(1)
+		go func(i int, bookingID uint) {
+			defer wg.Done()
+			<-start
+
+			inv, err := issue(db, bookingID)
+			if err != nil {
+				t.Errorf("issue the invoice of booking %d: %v", bookingID, err)
+				return
+			}
+
+			invoices[i] = inv
+		}(i, bookingID)
+	}
+
+	close(start)
+	wg.Wait()
+
+	return invoices
+}
+
+// TestIssue invoices are numbered in the order they are issued and a booking keeps the invoice it was issued
+func TestIssue(t *testing.T) {
+	storagetest.ForEachDialect(t, func(t *testing.T, db *gorm.DB) {
+		var numbers []uint
+		for _, bookingID := range []uint{4, 2, 4} {
+			inv, err := issue(db, bookingID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			numbers = append(numbers, inv.Number)
+		}
+
+		if want := []uint{1, 2, 1}; !slices.Equal(numbers, want) {
+			t.Errorf("numbers %v, want %v", numbers, want)
+		}
+	})
+}
+
+// TestIssueAtTheSameTime the first invoices asked for at the same time get every number once, in a row from 1, and
+// a booking asked for more than once at the same time is issued one invoice
+func TestIssueAtTheSameTime(t *testing.T) {
+	storagetest.ForEachLockingDialect(t, func(t *testing.T, db *gorm.DB) {
+		invoices := issueAtOnce(t, db, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+		var issued []uint
+		for _, inv := range invoices {
+			issued = append(issued, inv.Number)
+		}
+		slices.Sort(issued)
+
+		want := []uint{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+		if !slices.Equal(issued, want) {
+			t.Errorf("numbers %v, want %v", issued, want)
+		}
+
+		// asking again gives the same invoice
+		inv, err := issue(db, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if inv.Number != invoices[0].Number {
+			t.Errorf("second time booking 1 got number %d, want %d", inv.Number, invoices[0].Number)
+		}
+
+		// the same booking asked for at the same time
+		for _, inv = range issueAtOnce(t, db, 11, 11, 11, 11, 11) {
+			if inv.Number != 11 {
+				t.Errorf("booking 11 got number %d, want 11 every time", inv.Number)
+			}
+		}
+
+		var count int64
+		if err = db.Model(&Invoice{}).Where("booking_id = ?", 11).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		if count != 1 {
+			t.Errorf("booking 11 has %d invoices, want 1", count)
+		}
+	})
+}
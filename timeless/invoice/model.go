@@ -0,0 +1,37 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Invoice the invoice issued for a booking. Numbers come from InvoiceSequence so they are sequential with no gaps,
+// a booking keeps the number it was first issued with
+type Invoice struct {
+	gorm.Model
+	BookingID uint      `json:"bookingID" gorm:"uniqueIndex"`
+	Number    uint      `json:"number" gorm:"uniqueIndex"`
+	IssuedAt  time.Time `json:"issuedAt"`
+}
+
+// InvoiceSequence the last invoice number handed out, a single row that is locked while a number is taken
+type InvoiceSequence struct {
+	ID   uint `gorm:"primaryKey"`
+	Last uint
+}
+
+// Code the printed invoice number e.g. INV-000042
+func (i Invoice) Code() string {
+	return fmt.Sprintf("INV-%06d", i.Number)
+}
+
+// HotelDetails printed at the top of every invoice, they come from the config
+type HotelDetails struct {
+	Name     string
+	Address  string
+	Phone    string
+	Email    string
+	Currency string
+}
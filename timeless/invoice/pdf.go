@@ -0,0 +1,223 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/hidenkeys/timeless/customer"
+	"github.com/hidenkeys/timeless/room"
+)
+
+const (
+	pageWidth = 180.0 // A4 less the 15mm margins
+	rowHeight = 7.0
+)
+
+// render write the invoice PDF for a booking at hotel to w
+func render(w io.Writer, hotel HotelDetails, inv Invoice, booking room.Booking, guest customer.Customer, rooms []room.Room) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+
+	// the core fonts are cp1252, translate so accented guest names print properly
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// hotel and invoice details
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(pageWidth/2, 9, tr(hotel.Name), "", 0, "L", false, 0, "")
+	pdf.CellFormat(pageWidth/2, 9, "INVOICE", "", 1, "R", false, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	hotelLines := nonEmpty(hotel.Address, hotel.Phone, hotel.Email)
+	invoiceLines := []string{
+		"Invoice no: " + inv.Code(),
+		"Date: " + inv.IssuedAt.Format("02 Jan 2006"),
+		fmt.Sprintf("Booking: #%d", booking.ID),
+	}
+
+	for i := 0; i < max(len(hotelLines), len(invoiceLines)); i++ {
+		pdf.CellFormat(pageWidth/2, 5, tr(lineAt(hotelLines, i)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(pageWidth/2, 5, lineAt(invoiceLines, i), "", 1, "R", false, 0, "")
+	}
+
+	// customer details
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(pageWidth, 6, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+
+	for _, line := range nonEmpty(strings.TrimSpace(value(guest.FirstName)+" "+value(guest.LastName)), value(guest.Address), value(guest.Phone), value(guest.Email)) {
+		pdf.CellFormat(pageWidth, 5, tr(line), "", 1, "L", false, 0, "")
+	}
+
+	roomNames := make(map[uint]string, len(rooms))
+	for _, r := range rooms {
+		roomNames[r.ID] = value(r.Name)
+	}
+
+	// room bookings
+	pdf.Ln(6)
+	roomWidths := []float64{30, 30, 30, 20, 35, 35}
+	tableHeader(pdf, roomWidths, []string{"Room", "Check-in", "Check-out", "Nights", "Rate", "Amount"})
+
+	roomsTotal := 0.0
+	for _, roomBooking := range booking.RoomBookings {
+		total := roomBooking.Total()
+		roomsTotal += total
+
+		tableRow(pdf, roomWidths, []string{
+			tr(roomNames[roomBooking.RoomID]),
+			roomBooking.StartDate.Format(time.DateOnly),
+			roomBooking.EndDate.Format(time.DateOnly),
+			fmt.Sprint(roomBooking.NumberOfNights),
+			money(total / float64(max(roomBooking.NumberOfNights, 1))),
+			money(total),
+		})
+	}
+
+	// extra charges
+	if len(booking.Charges) > 0 {
+		pdf.Ln(4)
+		chargeWidths := []float64{35, 75, 20, 25, 25}
+		tableHeader(pdf, chargeWidths, []string{"Charge", "Description", "Qty", "Unit price", "Amount"})
+
+		for _, charge := range booking.Charges {
+			tableRow(pdf, chargeWidths, []string{
+				charge.Category,
+				tr(value(charge.Description)),
+				fmt.Sprint(charge.Quantity),
+				money(charge.UnitPrice),
+				money(charge.Amount),
+			})
+		}
+	}
+
+	// payments
+	if len(booking.Payments) > 0 {
+		pdf.Ln(4)
+		paymentWidths := []float64{35, 45, 65, 35}
+		tableHeader(pdf, paymentWidths, []string{"Paid on", "Method", "Reference", "Amount"})
+
+		for _, payment := range booking.Payments {
+			tableRow(pdf, paymentWidths, []string{
+				payment.PaidAt.Format(time.DateOnly),
+				tr(payment.Method),
+				tr(value(payment.Reference)),
+				money(payment.Amount),
+			})
+		}
+	}
+
+	// totals
+	pdf.Ln(6)
+	totals := [][2]string{{"Rooms", money(roomsTotal)}}
+
+	switch {
+	case booking.IsComplementary:
+		totals = append(totals, [2]string{"Complimentary", "-" + money(roomsTotal)})
+	case booking.Status == room.BookingStatusCancelled:
+		totals = append(totals, [2]string{"Cancelled, rooms not charged", "-" + money(roomsTotal)})
+		if booking.CancellationFee != nil {
+			totals = append(totals, [2]string{"Cancellation fee", money(*booking.CancellationFee)})
+		}
+	}
+
+	totals = append(totals,
+		[2]string{"Extra charges", money(booking.ChargesTotal)},
+		[2]string{"Total", money(booking.AmountOwed())},
+		[2]string{"Paid", money(booking.AmountPaid)},
+		[2]string{"Balance due (" + hotel.Currency + ")", money(booking.Balance)},
+	)
+
+	for i, total := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 11)
+		}
+
+		pdf.CellFormat(pageWidth-40, rowHeight, total[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, rowHeight, total[1], "", 1, "R", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+func tableHeader(pdf *fpdf.Fpdf, widths []float64, titles []string) {
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+
+	for i, title := range titles {
+		pdf.CellFormat(widths[i], rowHeight, title, "1", 0, align(i, len(titles)), true, 0, "")
+	}
+
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 10)
+}
+
+func tableRow(pdf *fpdf.Fpdf, widths []float64, cells []string) {
+	for i, cell := range cells {
+		pdf.CellFormat(widths[i], rowHeight, cell, "1", 0, align(i, len(cells)), false, 0, "")
+	}
+
+	pdf.Ln(-1)
+}
+
+// align the amount in the last column right
+func align(column, columns int) string {
+	if column == columns-1 {
+		return "R"
+	}
+
+	return "L"
+}
+
+// money format an amount with thousands separators e.g. 1,250,000.00
+func money(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	whole := fmt.Sprintf("%.2f", amount)
+	integer, fraction := whole[:len(whole)-3], whole[len(whole)-3:]
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String() + fraction
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func nonEmpty(lines ...string) []string {
+	var kept []string
+	for _, line := range lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+
+	return kept
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+
+	return ""
+}
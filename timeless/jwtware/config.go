@@ -15,6 +15,9 @@ import (
 var (
 	// ErrJWTAlg is returned when the JWT header did not contain the expected algorithm.
 	ErrJWTAlg = errors.New("the JWT header did not contain the expected algorithm")
+
+	// ErrJWTRevoked is returned when IsRevoked reports the token as revoked.
+	ErrJWTRevoked = errors.New("the JWT has been revoked")
 )
 
 // Config defines the config for JWT middleware
@@ -32,6 +35,12 @@ type Config struct {
 	// Optional. Default: 401 Invalid or expired JWT
 	ErrorHandler fiber.ErrorHandler
 
+	// IsRevoked defines a function which is executed for a token with a valid signature
+	// and reports whether it has been revoked, e.g. by looking its "jti" claim up in a session store.
+	// Revoked tokens are passed to ErrorHandler with ErrJWTRevoked.
+	// Optional. Default: nil
+	IsRevoked func(fiber.Ctx, *jwt.Token) (bool, error)
+
 	// Signing key to validate token. Used as fallback if SigningKeys has length 0.
 	// At least one of the following is required: KeyFunc, JWKSetURLs, SigningKeys, or SigningKey.
 	// The order of precedence is: KeyFunc, JWKSetURLs, SigningKeys, SigningKey.
@@ -49,6 +49,15 @@ func New(config ...Config) fiber.Handler {
 			claims := reflect.New(t).Interface().(jwt.Claims)
 			token, err = jwt.ParseWithClaims(auth, claims, cfg.KeyFunc)
 		}
+		if err == nil && token.Valid && cfg.IsRevoked != nil {
+			revoked, err := cfg.IsRevoked(c, token)
+			if err != nil {
+				return cfg.ErrorHandler(c, err)
+			}
+			if revoked {
+				return cfg.ErrorHandler(c, ErrJWTRevoked)
+			}
+		}
 		if err == nil && token.Valid {
 			// Store user information from token into context.
 			c.Locals(cfg.ContextKey, token)
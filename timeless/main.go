@@ -1,33 +1,121 @@
 package main
 
 import (
+	"io"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/config"
 	"github.com/hidenkeys/timeless/customer"
+	"github.com/hidenkeys/timeless/invoice"
+	"github.com/hidenkeys/timeless/migrations"
+	"github.com/hidenkeys/timeless/report"
 	"github.com/hidenkeys/timeless/room"
 	"github.com/hidenkeys/timeless/storage"
 	"github.com/hidenkeys/timeless/user"
+	"github.com/hidenkeys/timeless/validation"
+	"gorm.io/gorm"
 )
 
 func main() {
-	db, err := storage.ConnectDB()
+	cfg, err := config.Load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = db.AutoMigrate(&user.User{}, &room.Booking{}, &room.RoomBookings{}, &customer.Customer{}, &room.Room{})
+
+	db, err := storage.ConnectDB(cfg.Database.Driver, cfg.Database.Source())
 	if err != nil {
 		log.Fatal(err)
 	}
-	app := fiber.New(fiber.Config{AppName: "TIMELESS"})
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err = runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// development keeps its database up to date by itself, anywhere else migrating is a deliberate step
+	if cfg.Env == config.EnvDevelopment {
+		if _, err = migrations.Up(db); err != nil {
+			log.Fatal(err)
+		}
+	} else if pending, err := migrations.Pending(db); err != nil {
+		log.Fatal(err)
+	} else if len(pending) > 0 {
+		log.Fatalf("%d pending migrations, run `timeless migrate up` first", len(pending))
+	}
+
+	if err = migrations.SearchIndexes(db); err != nil {
+		log.Fatal(err)
+	}
+
+	if err = user.SeedAdmin(user.NewRepository(db), cfg.AdminPassword, terminal()); err != nil {
+		log.Fatal(err)
+	}
+
+	app := newApp(newHandlers(db, cfg), cfg.CORSOrigins)
+
+	err = app.Listen(cfg.Addr())
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// terminal stdout when it is an interactive terminal, nil when the output goes to a file or a log collector
+func terminal() io.Writer {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return os.Stdout
+	}
+
+	return nil
+}
+
+// newHandlers build every handler on db
+func newHandlers(db *gorm.DB, cfg config.Config) *handlers {
+	rooms := room.NewStore(db)
+	customers := customer.NewRepository(db)
+	users := user.NewRepository(db)
+	auth := user.NewAuthService(users, []byte(cfg.JWTSecret), cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	policy := room.CancellationPolicy{FreeWindow: cfg.Cancellation.FreeWindow, PenaltyNights: cfg.Cancellation.PenaltyNights}
+	hotel := invoice.HotelDetails{
+		Name:     cfg.Hotel.Name,
+		Address:  cfg.Hotel.Address,
+		Phone:    cfg.Hotel.Phone,
+		Email:    cfg.Hotel.Email,
+		Currency: cfg.Hotel.Currency,
+	}
+
+	return &handlers{
+		auth:      auth,
+		rooms:     room.NewHandler(rooms, room.NewBookingService(rooms, policy), room.NewHousekeepingService(rooms)),
+		users:     user.NewHandler(users, auth),
+		customers: customer.NewHandler(customers, rooms.Bookings()),
+		invoices:  invoice.NewHandler(db, rooms, customers, hotel),
+		reports:   report.NewHandler(db),
+		audit:     audit.NewHandler(audit.NewLog(db)),
+	}
+}
+
+// newApp the app serving every route of h under /api/v1 to the frontend at corsOrigins
+func newApp(h *handlers, corsOrigins []string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		AppName:         "TIMELESS",
+		ErrorHandler:    apierror.Handler,
+		StructValidator: validation.New(),
+	})
 
 	app.Use(cors.New(cors.Config{
-        AllowOrigins:     "http://localhost:5173", // Allow requests from this origin
-        AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-        AllowHeaders:     "Origin, Content-Type, Accept",
-        AllowCredentials: true,
-    }))
+		AllowOrigins:     strings.Join(corsOrigins, ","), // Allow requests from these origins
+		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowCredentials: true,
+	}))
 
 	api := app.Group("/api/v1")
 
@@ -35,14 +123,19 @@ func main() {
 	usersApi := api.Group("/users")
 	roomsApi := api.Group("/rooms")
 	customersApi := api.Group("/customers")
+	ratePlansApi := api.Group("/ratePlans")
+	reportsApi := api.Group("/reports")
+	housekeepingApi := api.Group("/housekeeping")
+	auditApi := api.Group("/audit")
 
-	bookingRoutes(bookingsApi)
-	userRoutes(usersApi)
-	roomRoutes(roomsApi)
-	customerRoutes(customersApi)
+	h.bookingRoutes(bookingsApi)
+	h.userRoutes(usersApi)
+	h.roomRoutes(roomsApi)
+	h.customerRoutes(customersApi)
+	h.ratePlanRoutes(ratePlansApi)
+	h.reportRoutes(reportsApi)
+	h.housekeepingRoutes(housekeepingApi)
+	h.auditRoutes(auditApi)
 
-	err = app.Listen(":3000")
-	if err != nil {
-		log.Fatal(err)
-	}
+	return app
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/config"
+	"github.com/hidenkeys/timeless/customer"
+	"github.com/hidenkeys/timeless/rbac"
+	"github.com/hidenkeys/timeless/room"
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"github.com/hidenkeys/timeless/user"
+	"gorm.io/gorm"
+)
+
+// signIn open a session for a new user with role and return the access token to send with their requests
+func signIn(t *testing.T, h *handlers, db *gorm.DB, role string) string {
+	t.Helper()
+
+	email, employeeID := role+"@timeless.test", role
+	u := &user.User{Email: &email, EmployeeID: &employeeID, Password: "password", Role: role}
+	storagetest.Create(t, db, u)
+
+	tokens, err := h.auth.StartSession(*u, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	return tokens["token"].(string)
+}
+
+// TestConcurrentBookingsOfOneRoom receptionists booking the same room for the same nights at the same time,
+// only one of them can get it
+func TestConcurrentBookingsOfOneRoom(t *testing.T) {
+	cfg := config.Default()
+	db := storagetest.NewFile(t)
+	h := newHandlers(db, cfg)
+	app := newApp(h, cfg.CORSOrigins)
+	token := signIn(t, h, db, rbac.RoleReceptionist)
+
+	name, firstName, lastName := "101", "John", "Doe"
+	storagetest.Create(t, db,
+		&room.Room{Name: &name, Price: 50000},
+		&customer.Customer{FirstName: &firstName, LastName: &lastName},
+	)
+
+	// the requests of a round all ask for the same nights, every round for other nights. one round is
+	// often over before the requests overlap, several give them more chances to
+	const rounds, requests = 10, 20
+
+	for round := 0; round < rounds; round++ {
+		body := fmt.Sprintf(`{"customerID":1,"paymentMethod":"cash","roomBookings":[{"numberOfNights":2,"roomID":1,"startDate":"2030-12-%02dT00:00:00Z"}]}`, 1+3*round)
+
+		start := make(chan struct{})
+		statuses := make(chan int, requests)
+
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/bookings", strings.NewReader(body))
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+
+				resp, err := app.Test(req, 10*time.Second)
+				if err != nil {
+					t.Errorf("book: %v", err)
+					return
+				}
+				resp.Body.Close()
+
+				statuses <- resp.StatusCode
+			}()
+		}
+
+		close(start)
+		wg.Wait()
+		close(statuses)
+
+		counts := make(map[int]int)
+		for status := range statuses {
+			counts[status]++
+		}
+
+		if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != requests-1 {
+			t.Fatalf("round %d: want 1 booking created and %d refused with 409, got statuses %v", round, requests-1, counts)
+		}
+	}
+
+	var booked int64
+	if err := db.Model(&room.RoomBookings{}).Where("room_id = ?", 1).Count(&booked).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if booked != rounds {
+		t.Fatalf("want %d room bookings saved, got %d", rounds, booked)
+	}
+}
+
+// TestRequireAuth a bad or revoked token is refused with 401, failing to look its session up is a 500
+func TestRequireAuth(t *testing.T) {
+	cfg := config.Default()
+	db := storagetest.New(t)
+	h := newHandlers(db, cfg)
+	app := newApp(h, cfg.CORSOrigins)
+	token := signIn(t, h, db, rbac.RoleAdmin)
+
+	status := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	if got := status(http.MethodGet, "/api/v1/rooms", token); got != http.StatusOK {
+		t.Fatalf("open session: status %d, want 200", got)
+	}
+
+	if got := status(http.MethodGet, "/api/v1/rooms", token+"x"); got != http.StatusUnauthorized {
+		t.Errorf("bad signature: status %d, want 401", got)
+	}
+
+	revoked := signIn(t, h, db, rbac.RoleManager)
+	if got := status(http.MethodPost, "/api/v1/users/auth/logout", revoked); got >= http.StatusBadRequest {
+		t.Fatalf("log out: status %d", got)
+	}
+
+	if got := status(http.MethodGet, "/api/v1/rooms", revoked); got != http.StatusUnauthorized {
+		t.Errorf("revoked session: status %d, want 401", got)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	if got := status(http.MethodGet, "/api/v1/rooms", token); got != http.StatusInternalServerError {
+		t.Errorf("sessions can't be read: status %d, want 500", got)
+	}
+}
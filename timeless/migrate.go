@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hidenkeys/timeless/migrations"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = "usage: timeless migrate up | down [steps] | status"
+
+// runMigrate the `timeless migrate` command
+func runMigrate(db *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := migrations.Up(db)
+		for _, m := range done {
+			fmt.Printf("applied %04d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errors.New("steps must be a positive number")
+			}
+			steps = n
+		}
+
+		done, err := migrations.Down(db, steps)
+		for _, m := range done {
+			fmt.Printf("rolled back %04d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+		return err
+
+	case "status":
+		statuses, err := migrations.Statuses(db)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d  %-30s %s\n", status.Version, status.Name, applied)
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
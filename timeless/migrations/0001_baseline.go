@@ -0,0 +1,196 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// the schema as AutoMigrate last built it. These are copies of the models at that point so the
+// baseline never changes, later changes to the models get their own migration. Running it on a
+// database AutoMigrate already built changes nothing
+
+type baselineCustomer struct {
+	gorm.Model
+	FirstName        *string
+	LastName         *string
+	Phone            *string
+	Address          *string
+	EmergencyContact *string
+	Email            *string
+	PlateNumber      *string
+	Bookings         []baselineBooking `gorm:"foreignKey:CustomerID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+func (baselineCustomer) TableName() string { return "customers" }
+
+type baselineUser struct {
+	gorm.Model
+	Email            *string `gorm:"unique;size:255"`
+	Password         string
+	EmployeeID       *string `gorm:"unique;size:255"`
+	FirstName        *string
+	LastName         *string
+	Phone            *string
+	EmergencyContact *string
+	IsAdmin          bool `gorm:"default:false"`
+	Role             string
+	Salary           float64
+	Bookings         []baselineBooking `gorm:"foreignKey:Receptionist"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineSession struct {
+	gorm.Model
+	UserID           uint `gorm:"index"`
+	RefreshTokenHash string
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+	UserAgent        string
+	IP               string
+}
+
+func (baselineSession) TableName() string { return "sessions" }
+
+type baselineRoom struct {
+	gorm.Model
+	Name         *string
+	Category     *string
+	Description  *string
+	Price        float64
+	Status       *string                `gorm:"default:available"`
+	RoomBookings []baselineRoomBookings `gorm:"foreignKey:RoomID"`
+}
+
+func (baselineRoom) TableName() string { return "rooms" }
+
+type baselineBooking struct {
+	gorm.Model
+	CustomerID         *uint
+	Receptionist       uint
+	Amount             *float64
+	IsPaid             bool
+	PaymentMethod      string
+	IsComplementary    bool                    `gorm:"default:false"`
+	RoomBookings       []*baselineRoomBookings `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,onDelete:CASCADE"`
+	Status             string                  `gorm:"default:active"`
+	CancelledAt        *time.Time
+	CancelledBy        *uint
+	CancellationReason *string
+	CancellationFee    *float64
+	RefundAmount       *float64
+	Payments           []*baselinePayment `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Charges            []*baselineCharge  `gorm:"foreignKey:BookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (baselineBooking) TableName() string { return "bookings" }
+
+type baselineRoomBookings struct {
+	gorm.Model
+	NumberOfNights       uint
+	CheckedIn            bool `gorm:"default:false"`
+	CheckedOut           bool `gorm:"default:false"`
+	StartDate            time.Time
+	EndDate              time.Time
+	Amount               *float64
+	BookingID            uint
+	RoomID               uint
+	CheckOutOverriddenBy *uint
+	Nights               []*baselineRoomBookingNight `gorm:"foreignKey:RoomBookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (baselineRoomBookings) TableName() string { return "room_bookings" }
+
+type baselineRoomBookingNight struct {
+	gorm.Model
+	RoomBookingID uint
+	Date          time.Time
+	Rate          float64
+	RatePlanID    *uint
+}
+
+func (baselineRoomBookingNight) TableName() string { return "room_booking_nights" }
+
+type baselineRatePlan struct {
+	gorm.Model
+	Name          *string
+	RoomID        *uint
+	Category      *string
+	StartDate     *time.Time
+	EndDate       *time.Time
+	Price         float64
+	WeekendUplift float64
+	MinimumStay   uint
+	Priority      int
+}
+
+func (baselineRatePlan) TableName() string { return "rate_plans" }
+
+type baselinePayment struct {
+	gorm.Model
+	BookingID    uint
+	Amount       float64
+	Method       string
+	Reference    *string
+	Receptionist uint
+	PaidAt       time.Time
+}
+
+func (baselinePayment) TableName() string { return "payments" }
+
+type baselineCharge struct {
+	gorm.Model
+	BookingID     uint
+	RoomBookingID *uint
+	Category      string
+	Description   *string
+	Quantity      uint
+	UnitPrice     float64
+	Amount        float64
+	PostedBy      uint
+}
+
+func (baselineCharge) TableName() string { return "charges" }
+
+type baselineInvoice struct {
+	gorm.Model
+	BookingID uint `gorm:"uniqueIndex"`
+	Number    uint `gorm:"uniqueIndex"`
+	IssuedAt  time.Time
+}
+
+func (baselineInvoice) TableName() string { return "invoices" }
+
+type baselineInvoiceSequence struct {
+	ID   uint `gorm:"primaryKey"`
+	Last uint
+}
+
+func (baselineInvoiceSequence) TableName() string { return "invoice_sequences" }
+
+// baselineTables tables referenced by foreign keys come first, postgres and mysql won't create a key to a missing table
+var baselineTables = []any{
+	&baselineCustomer{}, &baselineUser{}, &baselineRoom{}, &baselineSession{},
+	&baselineBooking{}, &baselineRoomBookings{}, &baselineRoomBookingNight{},
+	&baselineRatePlan{}, &baselinePayment{}, &baselineCharge{},
+	&baselineInvoice{}, &baselineInvoiceSequence{},
+}
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(baselineTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			for i := len(baselineTables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(baselineTables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
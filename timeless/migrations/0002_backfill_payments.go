@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// bookings marked paid before the payment ledger existed get one payment for what they owed,
+// so their balance comes out right
+const backfilledPaymentReference = "recorded before the payment ledger"
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "backfill payments",
+		Up: func(tx *gorm.DB) error {
+			now := time.Now()
+			return tx.Exec(`
+			insert into payments (created_at, updated_at, booking_id, amount, method, reference, receptionist, paid_at)
+			select ?, ?, id, owed, payment_method, ?, receptionist, coalesce(updated_at, created_at)
+			from (
+				select *, case when status = 'cancelled' then coalesce(cancellation_fee, 0) else coalesce(amount, 0) end as owed
+				from bookings
+				where is_paid = ? and is_complementary = ? and deleted_at is null
+					and id not in (select booking_id from payments)
+			) paid
+			where owed > 0
+			`, now, now, backfilledPaymentReference, true, false).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("delete from payments where reference = ?", backfilledPaymentReference).Error
+		},
+	})
+}
@@ -0,0 +1,116 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// the full text indexes of the customer and employee search. they only exist on SQLite built with FTS5
+// (make build, or go build -tags sqlite_fts5), without it search falls back to LIKE. see SearchIndexes for building
+// with FTS5 later
+var searchIndexes = []struct {
+	table   string
+	columns []string
+}{
+	{"customers", []string{"first_name", "last_name", "email", "phone", "plate_number"}},
+	{"users", []string{"first_name", "last_name", "email", "phone", "employee_id"}},
+}
+
+func hasFTS5(tx *gorm.DB) (bool, error) {
+	if tx.Dialector.Name() != "sqlite" {
+		return false, nil
+	}
+
+	var enabled bool
+	err := tx.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error
+	return enabled, err
+}
+
+// SearchIndexes create the search indexes that are missing when SQLite has FTS5. migration 3 runs it and so does
+// every start, so a database migrated by a build without FTS5 gets its indexes the first time a build with it starts
+func SearchIndexes(db *gorm.DB) error {
+	return db.Transaction(createSearchIndexes)
+}
+
+func createSearchIndexes(tx *gorm.DB) error {
+	enabled, err := hasFTS5(tx)
+	if err != nil || !enabled {
+		return err
+	}
+
+	for _, index := range searchIndexes {
+		fts := index.table + "_fts"
+
+		var count int64
+		if err = tx.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", fts).Scan(&count).Error; err != nil {
+			return err
+		}
+
+		// created already, by the migration or an earlier start
+		if count > 0 {
+			continue
+		}
+
+		columns := strings.Join(index.columns, ", ")
+		newValues := "new." + strings.Join(index.columns, ", new.")
+		oldValues := "old." + strings.Join(index.columns, ", old.")
+
+		// an external content index, the rows stay in the table and the triggers keep the index in step with them
+		statements := []string{
+			fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s', content_rowid='id')", fts, columns, index.table),
+			fmt.Sprintf(`CREATE TRIGGER %[1]s_insert AFTER INSERT ON %[2]s BEGIN
+					INSERT INTO %[1]s (rowid, %[3]s) VALUES (new.id, %[4]s);
+				END`, fts, index.table, columns, newValues),
+			fmt.Sprintf(`CREATE TRIGGER %[1]s_delete AFTER DELETE ON %[2]s BEGIN
+					INSERT INTO %[1]s (%[1]s, rowid, %[3]s) VALUES ('delete', old.id, %[4]s);
+				END`, fts, index.table, columns, oldValues),
+			fmt.Sprintf(`CREATE TRIGGER %[1]s_update AFTER UPDATE ON %[2]s BEGIN
+					INSERT INTO %[1]s (%[1]s, rowid, %[3]s) VALUES ('delete', old.id, %[4]s);
+					INSERT INTO %[1]s (rowid, %[3]s) VALUES (new.id, %[5]s);
+				END`, fts, index.table, columns, oldValues, newValues),
+			// index the rows already there
+			fmt.Sprintf("INSERT INTO %[1]s (%[1]s) VALUES ('rebuild')", fts),
+		}
+
+		for _, statement := range statements {
+			if err = tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "search indexes",
+		Up:      createSearchIndexes,
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "sqlite" {
+				return nil
+			}
+
+			for _, index := range searchIndexes {
+				fts := index.table + "_fts"
+				statements := []string{
+					fmt.Sprintf("DROP TRIGGER IF EXISTS %s_insert", fts),
+					fmt.Sprintf("DROP TRIGGER IF EXISTS %s_delete", fts),
+					fmt.Sprintf("DROP TRIGGER IF EXISTS %s_update", fts),
+					fmt.Sprintf("DROP TABLE IF EXISTS %s", fts),
+				}
+
+				for _, statement := range statements {
+					if err := tx.Exec(statement).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		},
+	})
+}
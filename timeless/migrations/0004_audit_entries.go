@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditEntry the audit log as this migration creates it
+type auditEntry struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	ActorID    *uint     `gorm:"index"`
+	Action     string
+	EntityType string `gorm:"index:idx_audit_entries_entity"`
+	EntityID   uint   `gorm:"index:idx_audit_entries_entity"`
+	Before     string
+	After      string
+}
+
+func (auditEntry) TableName() string { return "audit_entries" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "audit entries",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&auditEntry{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&auditEntry{})
+		},
+	})
+}
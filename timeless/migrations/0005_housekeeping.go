@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// housekeepingTask the housekeeping tasks as this migration creates them
+type housekeepingTask struct {
+	gorm.Model
+	RoomID        uint `gorm:"index"`
+	RoomBookingID *uint
+	Type          string
+	Status        string `gorm:"index"`
+	Notes         *string
+	AssignedTo    *uint
+	ClaimedAt     *time.Time
+	CompletedAt   *time.Time
+}
+
+func (housekeepingTask) TableName() string { return "housekeeping_tasks" }
+
+// setRoomStatusDefault the default status of new rooms. sqlite can't change a column's default without rebuilding
+// the table, there the rooms keep the old default and Room.BeforeCreate fills the status in instead
+func setRoomStatusDefault(tx *gorm.DB, status string) error {
+	if tx.Dialector.Name() == "sqlite" {
+		return nil
+	}
+
+	return tx.Exec("ALTER TABLE rooms ALTER COLUMN status SET DEFAULT '" + status + "'").Error
+}
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "housekeeping",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&housekeepingTask{}); err != nil {
+				return err
+			}
+
+			// the free text statuses become housekeeping statuses: a room with a guest checked in is occupied,
+			// one that was being let is taken as inspected so it can still be checked into, anything else
+			// is dirty and has to be cleaned and inspected first
+			statements := []struct {
+				sql  string
+				vars []any
+			}{
+				{`update rooms set status = 'occupied' where id in (
+					select room_id from room_bookings where checked_in = ? and checked_out = ? and deleted_at is null
+				)`, []any{true, false}},
+				{"update rooms set status = 'inspected' where lower(status) = 'available'", nil},
+				{"update rooms set status = 'out_of_order' where lower(status) in ('out of order', 'out-of-order', 'maintenance')", nil},
+				{"update rooms set status = 'vacant_dirty' where status is null or status not in ('occupied', 'inspected', 'out_of_order')", nil},
+			}
+
+			for _, statement := range statements {
+				if err := tx.Exec(statement.sql, statement.vars...).Error; err != nil {
+					return err
+				}
+			}
+
+			return setRoomStatusDefault(tx, "vacant_clean")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("update rooms set status = case when status = 'occupied' then 'Unavailable' else 'available' end").Error; err != nil {
+				return err
+			}
+
+			if err := setRoomStatusDefault(tx, "available"); err != nil {
+				return err
+			}
+
+			return tx.Migrator().DropTable(&housekeepingTask{})
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maintenanceBlock the maintenance blocks as this migration creates them
+type maintenanceBlock struct {
+	gorm.Model
+	RoomID     uint `gorm:"index"`
+	StartDate  time.Time
+	EndDate    time.Time
+	Reason     string
+	AssignedTo *uint
+	ClosedAt   *time.Time
+	ClosedBy   *uint
+}
+
+func (maintenanceBlock) TableName() string { return "maintenance_blocks" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "maintenance blocks",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&maintenanceBlock{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&maintenanceBlock{})
+		},
+	})
+}
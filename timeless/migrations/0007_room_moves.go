@@ -0,0 +1,28 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// roomBookingMove the column this migration adds to room bookings
+type roomBookingMove struct {
+	MovedFromID *uint
+}
+
+func (roomBookingMove) TableName() string { return "room_bookings" }
+
+// dropColumn drop a column in place. gorm rebuilds the table to drop a column on sqlite, which loses its indexes
+func dropColumn(tx *gorm.DB, table, column string) error {
+	return tx.Exec("ALTER TABLE " + table + " DROP COLUMN " + column).Error
+}
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "room moves",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&roomBookingMove{}, "MovedFromID")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "room_bookings", "moved_from_id")
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// roomBookingStay the stay columns of room bookings before and after this migration
+type roomBookingStay struct {
+	CheckedIn    bool   `gorm:"default:false"`
+	CheckedOut   bool   `gorm:"default:false"`
+	Status       string `gorm:"default:reserved;index"`
+	CheckedInAt  *time.Time
+	CheckedOutAt *time.Time
+}
+
+func (roomBookingStay) TableName() string { return "room_bookings" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "stay statuses",
+		Up: func(tx *gorm.DB) error {
+			migrator := tx.Migrator()
+			for _, column := range []string{"Status", "CheckedInAt", "CheckedOutAt"} {
+				if err := migrator.AddColumn(&roomBookingStay{}, column); err != nil {
+					return err
+				}
+			}
+
+			if err := migrator.CreateIndex(&roomBookingStay{}, "Status"); err != nil {
+				return err
+			}
+
+			// when the guests of old stays really arrived and left wasn't recorded, those times stay empty
+			err := tx.Exec(`update room_bookings set status = case
+				when checked_out = ? then 'checked_out'
+				when checked_in = ? then 'checked_in'
+				when booking_id in (select id from bookings where status = 'cancelled') then 'cancelled'
+				else 'reserved'
+			end`, true, true).Error
+			if err != nil {
+				return err
+			}
+
+			for _, column := range []string{"checked_in", "checked_out"} {
+				if err = dropColumn(tx, "room_bookings", column); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			migrator := tx.Migrator()
+			for _, column := range []string{"CheckedIn", "CheckedOut"} {
+				if err := migrator.AddColumn(&roomBookingStay{}, column); err != nil {
+					return err
+				}
+			}
+
+			err := tx.Exec("update room_bookings set checked_in = (status = 'checked_in'), checked_out = (status = 'checked_out')").Error
+			if err != nil {
+				return err
+			}
+
+			if err = migrator.DropIndex(&roomBookingStay{}, "Status"); err != nil {
+				return err
+			}
+
+			for _, column := range []string{"status", "checked_in_at", "checked_out_at"} {
+				if err = dropColumn(tx, "room_bookings", column); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// sessionPreviousToken the column this migration adds to sessions
+type sessionPreviousToken struct {
+	PreviousRefreshTokenHash string
+}
+
+func (sessionPreviousToken) TableName() string { return "sessions" }
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "previous refresh tokens",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&sessionPreviousToken{}, "PreviousRefreshTokenHash")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "sessions", "previous_refresh_token_hash")
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// invoiceSequence the row of invoice_sequences this migration seeds
+type invoiceSequence struct {
+	ID   uint `gorm:"primaryKey"`
+	Last uint
+}
+
+func (invoiceSequence) TableName() string { return "invoice_sequences" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "invoice sequence",
+		// the sequence row is there before the first invoice, so issuing one only ever locks it and two first
+		// invoices can't both create it. a database that issued invoices already has it
+		Up: func(tx *gorm.DB) error {
+			var count int64
+			if result := tx.Model(&invoiceSequence{}).Where("id = ?", 1).Count(&count); result.Error != nil || count > 0 {
+				return result.Error
+			}
+
+			var last uint
+			if result := tx.Table("invoices").Select("coalesce(max(number), 0)").Scan(&last); result.Error != nil {
+				return result.Error
+			}
+
+			return tx.Create(&invoiceSequence{ID: 1, Last: last}).Error
+		},
+		// the row is kept, removing it would start the numbers again from 1
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
@@ -0,0 +1,165 @@
+// Package migrations holds the versioned schema changes. Every migration lives in its
+// own file named after its version and registers itself from init, Up applies the
+// pending ones in version order and Down rolls the latest ones back.
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration one versioned change to the schema or the data in it
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration a row in schema_migrations, one per applied migration
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status a migration and whether it has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var registered []Migration
+
+func register(m Migration) {
+	for _, existing := range registered {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("migrations %q and %q share version %d", existing.Name, m.Name, m.Version))
+		}
+	}
+
+	registered = append(registered, m)
+	slices.SortFunc(registered, func(a, b Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+}
+
+// All every migration in version order
+func All() []Migration {
+	return slices.Clone(registered)
+}
+
+func applied(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if result := db.Order("version").Find(&rows); result.Error != nil {
+		return nil, result.Error
+	}
+
+	versions := make(map[uint]SchemaMigration, len(rows))
+	for _, row := range rows {
+		versions[row.Version] = row
+	}
+
+	return versions, nil
+}
+
+// Statuses every migration with when it was applied, nil for pending ones
+func Statuses(db *gorm.DB) ([]Status, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(registered))
+	for _, m := range registered {
+		status := Status{Migration: m}
+		if row, ok := versions[m.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending the migrations not applied yet, in the order Up would apply them
+func Pending(db *gorm.DB) ([]Migration, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range registered {
+		if _, ok := versions[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// Up apply every pending migration, each in its own transaction with its schema_migrations row,
+// and stop at the first one that fails
+func Up(db *gorm.DB) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// Down roll back the latest steps applied migrations, newest first
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	versions, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(registered) - 1; i >= 0 && len(done) < steps; i-- {
+		m := registered[i]
+		if _, ok := versions[m.Version]; !ok {
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("rolling back migration %d %s: %w", m.Version, m.Name, err)
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
@@ -0,0 +1,114 @@
+// Package pagination reads the page, page_size, sort and order query parameters of the list
+// endpoints and wraps one page of results with the total so clients can page through them
+package pagination
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Sorts the values the sort parameter accepts, mapped to the column they sort by
+type Sorts map[string]string
+
+// Params the page asked for, Sort is a column from the endpoint's Sorts and Order is asc or desc
+type Params struct {
+	Page     int
+	PageSize int
+	Sort     string
+	Order    string
+}
+
+// FromQuery read the paging parameters {params: [page, page_size, sort, order]}. page starts at 1,
+// sort defaults to defaultSort in defaultOrder
+func FromQuery(c fiber.Ctx, sorts Sorts, defaultSort, defaultOrder string) (Params, error) {
+	params := Params{Page: 1, PageSize: DefaultPageSize, Sort: sorts[defaultSort], Order: defaultOrder}
+
+	if page := c.Query("page"); page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil || n < 1 {
+			return params, apierror.Field("page", "page must be a number from 1")
+		}
+		params.Page = n
+	}
+
+	if pageSize := c.Query("page_size"); pageSize != "" {
+		n, err := strconv.Atoi(pageSize)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return params, apierror.Field("page_size", fmt.Sprintf("page_size must be a number from 1 to %d", MaxPageSize))
+		}
+		params.PageSize = n
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		column, ok := sorts[sort]
+		if !ok {
+			names := make([]string, 0, len(sorts))
+			for name := range sorts {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+
+			return params, apierror.Field("sort", "sort must be one of "+strings.Join(names, ", "))
+		}
+		params.Sort = column
+	}
+
+	if order := strings.ToLower(c.Query("order")); order != "" {
+		if order != "asc" && order != "desc" {
+			return params, apierror.Field("order", "order must be asc or desc")
+		}
+		params.Order = order
+	}
+
+	return params, nil
+}
+
+// Scope order, offset and limit a query to the page. id breaks ties so rows don't move between pages
+func (p Params) Scope(db *gorm.DB) *gorm.DB {
+	if p.Sort != "" {
+		db = db.Order(p.Sort + " " + p.Order)
+	}
+
+	return p.Window(db.Order("id " + p.Order))
+}
+
+// Window offset and limit a query to the page, for queries that bring their own order
+func (p Params) Window(db *gorm.DB) *gorm.DB {
+	return db.Offset((p.Page - 1) * p.PageSize).Limit(p.PageSize)
+}
+
+// Page one page of a list endpoint, Next is the page after it and null on the last page
+type Page[T any] struct {
+	Data     []T   `json:"data"`
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
+	Next     *int  `json:"next"`
+}
+
+// New wrap the rows of the page asked for with the total number of rows
+func New[T any](data []T, total int64, params Params) Page[T] {
+	if data == nil {
+		data = []T{}
+	}
+
+	page := Page[T]{Data: data, Total: total, Page: params.Page, PageSize: params.PageSize}
+
+	if int64(params.Page*params.PageSize) < total {
+		next := params.Page + 1
+		page.Next = &next
+	}
+
+	return page
+}
@@ -0,0 +1,180 @@
+package rbac
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hidenkeys/timeless/apierror"
+)
+
+const (
+	RoleReceptionist = "receptionist"
+	RoleManager      = "manager"
+	RoleHousekeeping = "housekeeping"
+	RoleAccountant   = "accountant"
+	RoleAdmin        = "admin"
+)
+
+// Roles every role a user can be given
+var Roles = []string{RoleReceptionist, RoleManager, RoleHousekeeping, RoleAccountant, RoleAdmin}
+
+// Permission something a route lets a user do, written as resource:action
+type Permission string
+
+const (
+	BookingRead      Permission = "booking:read"
+	BookingCreate    Permission = "booking:create"
+	BookingUpdate    Permission = "booking:update"
+	BookingCancel    Permission = "booking:cancel"
+	BookingDelete    Permission = "booking:delete"
+	BookingCheckIn   Permission = "booking:checkin"
+	CheckOutOverride Permission = "checkout:override"
+	PaymentRecord    Permission = "payment:record"
+	FolioPost        Permission = "folio:post"
+	FolioVoid        Permission = "folio:void"
+	RoomRead         Permission = "room:read"
+	RoomManage       Permission = "room:manage"
+	RoomInspect      Permission = "room:inspect"
+	RatePlanManage   Permission = "rateplan:manage"
+	CustomerRead     Permission = "customer:read"
+	CustomerWrite    Permission = "customer:write"
+	CustomerDelete   Permission = "customer:delete"
+	ReportView       Permission = "report:view"
+	ReportExport     Permission = "report:export"
+	UserRead         Permission = "user:read"
+	UserManage       Permission = "user:manage"
+	AuditView        Permission = "audit:view"
+
+	HousekeepingRead   Permission = "housekeeping:read"
+	HousekeepingWork   Permission = "housekeeping:work"
+	HousekeepingManage Permission = "housekeeping:manage"
+	MaintenanceManage  Permission = "maintenance:manage"
+)
+
+var receptionistPermissions = []Permission{
+	BookingRead, BookingCreate, BookingUpdate, BookingCancel, BookingCheckIn,
+	PaymentRecord, FolioPost,
+	RoomRead,
+	CustomerRead, CustomerWrite,
+	HousekeepingRead,
+}
+
+// rolePermissions what each role may do, admins may do everything
+var rolePermissions = map[string][]Permission{
+	RoleReceptionist: receptionistPermissions,
+	RoleManager: append(slices.Clone(receptionistPermissions),
+		BookingDelete, CheckOutOverride, FolioVoid,
+		RoomManage, RoomInspect, MaintenanceManage, RatePlanManage,
+		HousekeepingWork, HousekeepingManage,
+		CustomerDelete,
+		ReportView, ReportExport,
+		UserRead,
+		AuditView,
+	),
+	RoleHousekeeping: {RoomRead, HousekeepingRead, HousekeepingWork},
+	RoleAccountant: {
+		BookingRead, PaymentRecord, FolioVoid,
+		RoomRead,
+		CustomerRead,
+		ReportView, ReportExport,
+		AuditView,
+	},
+}
+
+// ValidRole check if role is one of Roles
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, strings.ToLower(role))
+}
+
+// Can check if a role grants a permission
+func Can(role string, permission Permission) bool {
+	role = strings.ToLower(role)
+	if role == RoleAdmin {
+		return true
+	}
+
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// claims get the claims of the token stored by jwtware, nil when the request isn't authenticated
+func claims(c fiber.Ctx) jwt.MapClaims {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return nil
+	}
+
+	mapClaims, _ := token.Claims.(jwt.MapClaims)
+	return mapClaims
+}
+
+// Role get the role of the authenticated user, users with the is_admin claim are admins whatever their role
+func Role(c fiber.Ctx) string {
+	userClaims := claims(c)
+
+	if isAdmin, _ := userClaims["is_admin"].(bool); isAdmin {
+		return RoleAdmin
+	}
+
+	role, _ := userClaims["role"].(string)
+	return role
+}
+
+// UserID get the id of the authenticated user, 0 when the request isn't authenticated
+func UserID(c fiber.Ctx) uint {
+	id, _ := claims(c)["user_id"].(float64)
+	return uint(id)
+}
+
+// Allowed check if the authenticated user has every one of the permissions
+func Allowed(c fiber.Ctx, permissions ...Permission) bool {
+	if claims(c) == nil {
+		return false
+	}
+
+	role := Role(c)
+	for _, permission := range permissions {
+		if !Can(role, permission) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Require only let the request through when the authenticated user has every one of the permissions,
+// must run after the jwt middleware
+func Require(permissions ...Permission) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if claims(c) == nil {
+			return apierror.Unauthorized("invalid or expired JWT")
+		}
+
+		if !Allowed(c, permissions...) {
+			return apierror.Forbidden("you don't have permission to do this")
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireSelfOr let users act on their own record (the :param route parameter is their id), anyone else needs the permissions
+func RequireSelfOr(param string, permissions ...Permission) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if claims(c) == nil {
+			return apierror.Unauthorized("invalid or expired JWT")
+		}
+
+		if id := UserID(c); id != 0 && c.Params(param) == strconv.FormatUint(uint64(id), 10) {
+			return c.Next()
+		}
+
+		if !Allowed(c, permissions...) {
+			return apierror.Forbidden("you don't have permission to do this")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,250 @@
+package report
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/room"
+	"github.com/hidenkeys/timeless/storage"
+	"gorm.io/gorm"
+)
+
+// Metrics the occupancy and revenue figures for a day or for the whole report
+type Metrics struct {
+	RoomNightsAvailable    uint               `json:"roomNightsAvailable"`
+	RoomNightsSold         uint               `json:"roomNightsSold"`
+	ComplementaryNights    uint               `json:"complementaryNights"`
+	Occupancy              float64            `json:"occupancy"` // % of available room nights occupied, complementary nights included
+	RoomRevenue            float64            `json:"roomRevenue"`
+	ADR                    float64            `json:"adr"`    // average daily rate, room revenue / room nights sold
+	RevPAR                 float64            `json:"revpar"` // revenue per available room, room revenue / room nights available
+	RevenueByCategory      map[string]float64 `json:"revenueByCategory"`
+	RevenueByPaymentMethod map[string]float64 `json:"revenueByPaymentMethod"`
+}
+
+type Day struct {
+	Date string `json:"date"`
+	Metrics
+}
+
+type Report struct {
+	Start  string  `json:"start"`
+	End    string  `json:"end"`
+	Rooms  int     `json:"rooms"`
+	Totals Metrics `json:"totals"`
+	Daily  []Day   `json:"daily"`
+}
+
+// Handler the report endpoints
+type Handler struct {
+	db *gorm.DB
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{db: db}
+}
+
+func newMetrics() Metrics {
+	return Metrics{
+		RevenueByCategory:      make(map[string]float64),
+		RevenueByPaymentMethod: make(map[string]float64),
+	}
+}
+
+// finish work out the ratios once the counts and revenue are added up
+func (m *Metrics) finish() {
+	if m.RoomNightsAvailable > 0 {
+		m.Occupancy = round(float64(m.RoomNightsSold+m.ComplementaryNights) / float64(m.RoomNightsAvailable) * 100)
+		m.RevPAR = round(m.RoomRevenue / float64(m.RoomNightsAvailable))
+	}
+
+	if m.RoomNightsSold > 0 {
+		m.ADR = round(m.RoomRevenue / float64(m.RoomNightsSold))
+	}
+}
+
+// GetReport {params [start, end]}
+// occupancy, ADR, RevPAR, room nights sold, revenue by category and by payment method for every day from start to end
+// (both included) and in total, defaults to the last 30 days
+func (h *Handler) GetReport(c fiber.Ctx) error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	end := today
+	if c.Query("end") != "" {
+		var err error
+		if end, err = time.Parse(time.DateOnly, c.Query("end")); err != nil {
+			return apierror.Field("end", "invalid end date, expected YYYY-MM-DD")
+		}
+	}
+
+	start := end.AddDate(0, 0, -29)
+	if c.Query("start") != "" {
+		var err error
+		if start, err = time.Parse(time.DateOnly, c.Query("start")); err != nil {
+			return apierror.Field("start", "invalid start date, expected YYYY-MM-DD")
+		}
+	}
+
+	if end.Before(start) {
+		return apierror.Field("end", "end date must not be before start date")
+	}
+
+	if end.Sub(start) > 366*24*time.Hour {
+		return apierror.Field("end", "a report can cover at most a year")
+	}
+
+	report, err := buildReport(h.db, start, end)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(report)
+}
+
+func buildReport(db *gorm.DB, start, end time.Time) (*Report, error) {
+	var rooms []room.Room
+	if result := db.Find(&rooms); result.Error != nil {
+		return nil, result.Error
+	}
+
+	categories := make(map[uint]string, len(rooms))
+	for _, r := range rooms {
+		categories[r.ID] = "uncategorised"
+		if r.Category != nil && *r.Category != "" {
+			categories[r.ID] = *r.Category
+		}
+	}
+
+	report := &Report{
+		Start:  start.Format(time.DateOnly),
+		End:    end.Format(time.DateOnly),
+		Rooms:  len(rooms),
+		Totals: newMetrics(),
+	}
+
+	days := make(map[string]*Day)
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		report.Daily = append(report.Daily, Day{Date: d.Format(time.DateOnly), Metrics: newMetrics()})
+	}
+	for i := range report.Daily {
+		report.Daily[i].RoomNightsAvailable = uint(len(rooms))
+		days[report.Daily[i].Date] = &report.Daily[i]
+	}
+
+	// every room booking with a night in the range, from bookings that weren't cancelled. no shows
+	// freed their room so they don't count
+	var roomBookings []*room.RoomBookings
+	if result := db.Preload("Nights").
+		Joins("JOIN bookings b ON b.id = room_bookings.booking_id").
+		Where("b.deleted_at IS NULL AND b.status != ?", room.BookingStatusCancelled).
+		Where("room_bookings.status NOT IN ?", []string{room.StayStatusNoShow, room.StayStatusCancelled}).
+		Where("date(room_bookings.start_date) <= ? AND date(room_bookings.end_date) > ?", end.Format(time.DateOnly), start.Format(time.DateOnly)).
+		Find(&roomBookings); result.Error != nil {
+		return nil, result.Error
+	}
+
+	var complementary []uint
+	if result := db.Model(room.Booking{}).Where("is_complementary = ?", true).Pluck("id", &complementary); result.Error != nil {
+		return nil, result.Error
+	}
+
+	isComplementary := make(map[uint]bool, len(complementary))
+	for _, id := range complementary {
+		isComplementary[id] = true
+	}
+
+	for _, roomBooking := range roomBookings {
+		for date, rate := range nightlyRates(roomBooking) {
+			day, ok := days[date]
+			if !ok {
+				continue
+			}
+
+			if isComplementary[roomBooking.BookingID] {
+				day.ComplementaryNights++
+				continue
+			}
+
+			day.RoomNightsSold++
+			day.RoomRevenue += rate
+			day.RevenueByCategory[categories[roomBooking.RoomID]] += rate
+		}
+	}
+
+	// money taken, by the day it was paid
+	var payments []struct {
+		Day    storage.Date
+		Method string
+		Amount float64
+	}
+
+	if result := db.Model(room.Payment{}).
+		Select("date(paid_at) AS day, lower(method) AS method, sum(amount) AS amount").
+		Where("date(paid_at) >= ? AND date(paid_at) <= ?", start.Format(time.DateOnly), end.Format(time.DateOnly)).
+		Group("date(paid_at), lower(method)").
+		Scan(&payments); result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, payment := range payments {
+		if day, ok := days[payment.Day.String()]; ok {
+			day.RevenueByPaymentMethod[payment.Method] += payment.Amount
+		}
+	}
+
+	totals := &report.Totals
+	for i := range report.Daily {
+		day := &report.Daily[i]
+		day.finish()
+
+		totals.RoomNightsAvailable += day.RoomNightsAvailable
+		totals.RoomNightsSold += day.RoomNightsSold
+		totals.ComplementaryNights += day.ComplementaryNights
+		totals.RoomRevenue += day.RoomRevenue
+
+		for category, revenue := range day.RevenueByCategory {
+			totals.RevenueByCategory[category] += revenue
+		}
+
+		for method, amount := range day.RevenueByPaymentMethod {
+			totals.RevenueByPaymentMethod[method] += amount
+		}
+	}
+	totals.finish()
+
+	return report, nil
+}
+
+// nightlyRates the rate charged for each night of a room booking keyed by date
+func nightlyRates(roomBooking *room.RoomBookings) map[string]float64 {
+	rates := make(map[string]float64, roomBooking.NumberOfNights)
+
+	if len(roomBooking.Nights) > 0 {
+		for _, night := range roomBooking.Nights {
+			rates[night.Date.UTC().Format(time.DateOnly)] = night.Rate
+		}
+
+		return rates
+	}
+
+	// room bookings made before per night pricing
+	rate := 0.0
+	if roomBooking.Amount != nil {
+		rate = *roomBooking.Amount
+	}
+
+	first := roomBooking.StartDate.UTC().Truncate(24 * time.Hour)
+	last := roomBooking.EndDate.UTC().Truncate(24 * time.Hour)
+	for night := first; night.Before(last); night = night.AddDate(0, 0, 1) {
+		rates[night.Format(time.DateOnly)] = rate
+	}
+
+	return rates
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,82 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hidenkeys/timeless/room"
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"gorm.io/gorm"
+)
+
+// TestMain stop the database servers ForEachDialect started
+func TestMain(m *testing.M) {
+	storagetest.Main(m)
+}
+
+func TestBuildReport(t *testing.T) {
+	storagetest.ForEachDialect(t, func(t *testing.T, db *gorm.DB) {
+		standard, deluxe := "standard", "deluxe"
+		rate := 100.0
+		first := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+		booking := &room.Booking{
+			Receptionist:  1,
+			PaymentMethod: "cash",
+			Status:        room.BookingStatusActive,
+			RoomBookings: []*room.RoomBookings{{
+				RoomID:         1,
+				NumberOfNights: 2,
+				StartDate:      first,
+				EndDate:        first.AddDate(0, 0, 2),
+				Amount:         &rate,
+				Status:         room.StayStatusCheckedOut,
+				Nights: []*room.RoomBookingNight{
+					{Date: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), Rate: 100},
+					{Date: time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC), Rate: 120},
+				},
+			}},
+			Payments: []*room.Payment{
+				{Amount: 150, Method: "Cash", PaidAt: time.Date(2026, time.March, 1, 18, 30, 0, 0, time.UTC)},
+				{Amount: 50, Method: "cash", PaidAt: time.Date(2026, time.March, 1, 22, 0, 0, 0, time.UTC)},
+				{Amount: 20, Method: "transfer", PaidAt: time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)},
+			},
+		}
+
+		storagetest.Create(t, db,
+			storagetest.NewUser(1),
+			&room.Room{Name: &standard, Category: &standard, Price: 100},
+			&room.Room{Name: &deluxe, Category: &deluxe, Price: 200},
+			booking,
+		)
+
+		report, err := buildReport(db, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, time.March, 3, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(report.Daily) != 3 {
+			t.Fatalf("%d days, want 3", len(report.Daily))
+		}
+
+		totals := report.Totals
+		if totals.RoomNightsAvailable != 6 || totals.RoomNightsSold != 2 || totals.RoomRevenue != 220 {
+			t.Errorf("%d of %d room nights sold for %v, want 2 of 6 for 220", totals.RoomNightsSold, totals.RoomNightsAvailable, totals.RoomRevenue)
+		}
+
+		if totals.RevenueByCategory[standard] != 220 {
+			t.Errorf("revenue by category %v, want standard 220", totals.RevenueByCategory)
+		}
+
+		// payments are grouped by the day they were made and by method whatever its case
+		firstDay := report.Daily[0].RevenueByPaymentMethod
+		if len(firstDay) != 1 || firstDay["cash"] != 200 {
+			t.Errorf("payments on the 1st %v, want cash 200", firstDay)
+		}
+
+		secondDay := report.Daily[1].RevenueByPaymentMethod
+		if len(secondDay) != 1 || secondDay["transfer"] != 20 {
+			t.Errorf("payments on the 2nd %v, want transfer 20", secondDay)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package room
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+// CancellationPolicy decides how much of a booking is kept when it is cancelled
+type CancellationPolicy struct {
+	// FreeWindow cancelling at least this long before a room booking's StartDate costs nothing
+	FreeWindow time.Duration
+	// PenaltyNights number of nights charged per room booking when cancelling inside the free window
+	PenaltyNights uint
+}
+
+// DefaultPolicy free up to 48h before StartDate and one night charged after that
+var DefaultPolicy = CancellationPolicy{
+	FreeWindow:    48 * time.Hour,
+	PenaltyNights: 1,
+}
+
+// Fee get the amount kept for cancelling the room bookings at the given time
+func (p CancellationPolicy) Fee(roomBookings []*RoomBookings, at time.Time) float64 {
+	fee := 0.0
+
+	for _, roomBooking := range roomBookings {
+		if at.Before(roomBooking.StartDate.Add(-p.FreeWindow)) {
+			continue
+		}
+
+		// the first nights of the stay are the ones charged
+		if len(roomBooking.Nights) > 0 {
+			fee += nightsTotal(roomBooking.Nights[:min(p.PenaltyNights, uint(len(roomBooking.Nights)))])
+		} else if roomBooking.Amount != nil {
+			fee += *roomBooking.Amount * float64(min(p.PenaltyNights, roomBooking.NumberOfNights))
+		}
+	}
+
+	return fee
+}
+
+type CancelBookingRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// CancelBooking cancel a booking, apply the cancellation policy and record the refund owed {body: [reason]}.
+// the booking is recorded as cancelled by the user making the request
+func (h *Handler) CancelBooking(c fiber.Ctx) error {
+	bookingId, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	cancelRequest := new(CancelBookingRequest)
+
+	if err = c.Bind().JSON(cancelRequest); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	booking, err := h.bookings.By(rbac.UserID(c)).Cancel(uint(bookingId), *cancelRequest)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(booking)
+}
@@ -0,0 +1,98 @@
+package room
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+// PostCharge post an incidental charge to a booking's folio {body: [category, description, quantity, unitPrice, roomBookingID]}.
+// the charge is recorded as posted by the user making the request
+func (h *Handler) PostCharge(c fiber.Ctx) error {
+	bookingId, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	charge := new(Charge)
+
+	if err = c.Bind().JSON(charge); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	if err = h.bookings.By(rbac.UserID(c)).PostCharge(uint(bookingId), charge); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(charge)
+}
+
+// VoidCharge remove a charge posted to a booking's folio by mistake
+func (h *Handler) VoidCharge(c fiber.Ctx) error {
+	bookingId, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	chargeId, err := strconv.Atoi(c.Params("chargeId"))
+
+	if err != nil {
+		return apierror.InvalidID("charge")
+	}
+
+	if err = h.bookings.By(rbac.UserID(c)).VoidCharge(uint(bookingId), uint(chargeId)); err != nil {
+		return err
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetFolio get a booking's folio, the room charges, incidental charges and payments with the balance
+func (h *Handler) GetFolio(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	booking, err := h.store.Bookings().FindFolio(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if booking.ID == 0 {
+		return apierror.NotFound("booking")
+	}
+
+	type roomLine struct {
+		RoomBookingID  uint    `json:"roomBookingID"`
+		RoomID         uint    `json:"roomID"`
+		NumberOfNights uint    `json:"numberOfNights"`
+		Amount         float64 `json:"amount"`
+	}
+
+	rooms := make([]roomLine, 0, len(booking.RoomBookings))
+	for _, roomBooking := range booking.RoomBookings {
+		rooms = append(rooms, roomLine{
+			RoomBookingID:  roomBooking.ID,
+			RoomID:         roomBooking.RoomID,
+			NumberOfNights: roomBooking.NumberOfNights,
+			Amount:         roomBooking.Total(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"rooms":        rooms,
+		"roomAmount":   booking.roomAmountOwed(),
+		"charges":      booking.Charges,
+		"chargesTotal": booking.ChargesTotal,
+		"payments":     booking.Payments,
+		"amountPaid":   booking.AmountPaid,
+		"balance":      booking.Balance,
+	})
+}
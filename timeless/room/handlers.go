@@ -1,137 +1,120 @@
 package room
 
 import (
-	"fmt"
-	"log"
 	"net/http"
-	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/hidenkeys/timeless/storage"
-	"gorm.io/gorm"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/rbac"
 )
 
-// GetAllBookings {params [start, end, employeeId]} // TODO: paging
-// get all booking within date range (start and end)
-func GetAllBookings(c fiber.Ctx) error {
-	//limit := c.Query("page_size", "10")
-	//offset := c.Query("page", "0")
-
-	start := c.Query("start")
-	end := c.Query("end")
-	employeeId := c.Query("employeeId")
-
-	var generateSQL strings.Builder
-	generateSQL.WriteString("SELECT * FROM bookings")
-	var params []any
-	var whereClause strings.Builder
-
-	whereClause.WriteString("deleted_at is ? ")
-	params = append(params, nil)
-
-	if start != "" {
-		if len(params) != 0 {
-			whereClause.WriteString("AND ")
-		}
-		whereClause.WriteString("created_at >= ? ")
-		params = append(params, start)
-	}
-
-	if end != "" {
-		if len(params) != 0 {
-			whereClause.WriteString("AND ")
-		}
-		whereClause.WriteString("created_at <= ? ")
-		params = append(params, fmt.Sprintf("%sT23:59", end))
-	}
-
-	if employeeId != "" {
-		if len(params) != 0 {
-			whereClause.WriteString("AND ")
-		}
-		whereClause.WriteString("employee_id == ? ")
-		params = append(params, employeeId)
-	}
+// Handler the room, booking and rate plan endpoints
+type Handler struct {
+	store        Store
+	bookings     *BookingService
+	housekeeping *HousekeepingService
+}
 
-	if whereClause.Len() > 0 {
-		generateSQL.WriteString(" WHERE ")
-		generateSQL.WriteString(whereClause.String())
-		generateSQL.WriteString("ORDER BY created_at desc")
+func NewHandler(store Store, bookings *BookingService, housekeeping *HousekeepingService) *Handler {
+	return &Handler{store: store, bookings: bookings, housekeeping: housekeeping}
+}
 
+// GetAllBookings {params [start, end, employeeId, status, page, page_size, sort, order]}
+// get a page of the bookings made within date range (start and end) by the receptionist employeeId, newest first by default.
+// cancelled bookings are only returned for status=cancelled
+func (h *Handler) GetAllBookings(c fiber.Ctx) error {
+	page, err := pagination.FromQuery(c, BookingSorts, "createdAt", "desc")
+	if err != nil {
+		return err
 	}
-	//generateSQL.WriteString(" LIMIT ? OFFSET ?")
-	//params = append(params, limit)
-	//params = append(params, offset)
 
-	var bookings []Booking
-	if result := storage.DB.Preload("RoomBookings").Raw(generateSQL.String(), params...).Find(&bookings); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	bookings, total, err := h.store.Bookings().List(BookingFilter{
+		Start:      c.Query("start"),
+		End:        c.Query("end"),
+		EmployeeID: c.Query("employeeId"),
+		Status:     c.Query("status"),
+	}, page)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(bookings)
+	return c.Status(http.StatusOK).JSON(pagination.New(bookings, total, page))
 }
 
 // GetBookingById get booking by booking id
-func GetBookingById(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) GetBookingById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid booking id")
+	if err != nil {
+		return apierror.InvalidID("booking")
 	}
 
-	var booking Booking
-
-	if result := storage.DB.Preload("RoomBookings").Raw("SELECT * FROM bookings WHERE id == ?", id).Scan(&booking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	booking, err := h.store.Bookings().FindFolio(uint(id))
+	if err != nil {
+		return err
 	}
 
 	if booking.ID == 0 {
-		return c.Status(http.StatusBadRequest).SendString("invalid booking id")
+		return apierror.NotFound("booking")
 	}
 
 	return c.Status(http.StatusOK).JSON(booking)
 }
 
-// ChangePaymentStatus change payment plan
-func ChangePaymentStatus(c fiber.Ctx) error {
-	id := c.Params("id")
+// ChangePaymentStatus mark a booking paid by recording a payment of its outstanding balance {params: [method, reference]}
+func (h *Handler) ChangePaymentStatus(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 	paymentMethod := c.Query("method")
 
-	if id == "" || paymentMethod == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid booking id")
+	if err != nil {
+		return apierror.InvalidID("booking")
 	}
 
-	if result := storage.DB.Exec("UPDATE bookings SET is_paid = true AND payment_method = ? WHERE id == ?", paymentMethod, id); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if paymentMethod == "" {
+		return apierror.Field("method", "payment method is required")
 	}
 
-	return c.SendStatus(http.StatusOK)
+	booking, err := h.bookings.By(rbac.UserID(c)).MarkPaid(uint(id), paymentMethod, c.Query("reference"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(booking)
 }
 
+// UpdateBookingRequest the new details of a booking and the new dates of one of its room bookings, the stay ends
+// numberOfNights after startDate
 type UpdateBookingRequest struct {
 	CustomerID      *uint     `json:"customerID" validate:"required"`
-	Receptionist    uint      `json:"receptionist"`
-	IsPaid          bool      `json:"isPaid"`
 	PaymentMethod   string    `json:"paymentMethod" validate:"required"`
 	IsComplementary bool      `json:"isComplementary" gorm:"default:false"`
-	NumberOfNights  uint      `json:"numberOfNights" validate:"required"`
+	NumberOfNights  uint      `json:"numberOfNights" validate:"min=1"`
 	StartDate       time.Time `json:"startDate" validate:"required"`
-	EndDate         time.Time `json:"endDate" validate:"required"`
 	Amount          *float64  `json:"amount"`
-	BookingID       uint      `json:"bookingID"`
-	RoomID          uint      `json:"roomID"`
 }
 
-func UpdateBooking(c fiber.Ctx) error {
-	newBookingInfo := new(UpdateBookingRequest)
-	bookingID, _ := strconv.Atoi(c.Params("bookingId"))
-	roomBookingID, _ := strconv.Atoi(c.Params("roomBookingId"))
+// UpdateBooking move the dates of a room booking and change the booking's details
+func (h *Handler) UpdateBooking(c fiber.Ctx) error {
+	bookingID, err := strconv.Atoi(c.Params("bookingId"))
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	roomBookingID, err := strconv.Atoi(c.Params("roomBookingId"))
+	if err != nil {
+		return apierror.InvalidID("room booking")
+	}
 
+	newBookingInfo := new(UpdateBookingRequest)
 	if err := c.Bind().JSON(&newBookingInfo); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
+	}
+
+	if err := h.bookings.By(rbac.UserID(c)).Update(uint(bookingID), uint(roomBookingID), newBookingInfo); err != nil {
+		return err
 	}
 
 	booking := new(Booking)
@@ -140,35 +123,6 @@ func UpdateBooking(c fiber.Ctx) error {
 	roomBooking := new(RoomBookings)
 	roomBooking.ID = uint(roomBookingID)
 
-	var start time.Time
-	var _ time.Time
-
-	start = newBookingInfo.StartDate.Add(12 * time.Hour)
-	newBookingInfo.EndDate = start.AddDate(0, 0, int(newBookingInfo.NumberOfNights))
-
-	if result := storage.DB.Model(&booking).Updates(newBookingInfo); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("Failed to update booking")
-	}
-
-	if result := storage.DB.Model(&roomBooking).Updates(newBookingInfo); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("Failed to update room booking")
-	}
-
-	var checkBooking Booking
-	if result := storage.DB.Raw("Select * from bookings where id = ?", bookingID).Find(&checkBooking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("Failed")
-	}
-	var checkRoomBooking RoomBookings
-	if result := storage.DB.Raw("Select * from room_bookings where id = ?", roomBookingID).Find(&checkRoomBooking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("Failed")
-	}
-	amount := 0.0
-	amount += *checkRoomBooking.Amount * float64(newBookingInfo.NumberOfNights)
-
-	if result := storage.DB.Model(&booking).Update("amount", amount); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("Failed to update booking")
-	}
-
 	return c.Status(http.StatusOK).JSON(fiber.Map{
 		"message":     "Booking and Room Booking updated successfully",
 		"booking":     booking,
@@ -176,494 +130,196 @@ func UpdateBooking(c fiber.Ctx) error {
 	})
 }
 
-// func UpdateBooking(c fiber.Ctx) error {
-// 	newBookingInfo := new(UpdateBookingRequest)
-// 	bookingID, _ := strconv.Atoi(c.Params("bookingId"))
-// 	roomBookingID, _ := strconv.Atoi(c.Params("roomBookingId"))
-
-// 	if err := c.Bind().JSON(&newBookingInfo); err != nil {
-// 		return c.Status(http.StatusBadRequest).JSON(err)
-// 	}
-
-// 	booking := new(Booking)
-// 	booking.ID = uint(bookingID)
-
-// 	roomBooking := new(RoomBookings)
-// 	roomBooking.ID = uint(roomBookingID)
-
-// 	var start time.Time
-// 	var _ time.Time
-
-// 	start = newBookingInfo.StartDate.Add(12 * time.Hour)
-// 	newBookingInfo.EndDate = start.AddDate(0, 0, int(newBookingInfo.NumberOfNights))
-
-// 	if result := storage.DB.Model(&booking).Updates(newBookingInfo); result.Error != nil {
-// 		return c.Status(http.StatusInternalServerError).SendString("Failed to update booking")
-// 	}
-
-// 	if result := storage.DB.Model(&roomBooking).Updates(newBookingInfo); result.Error != nil {
-// 		return c.Status(http.StatusInternalServerError).SendString("Failed to update room booking")
-// 	}
-
-// 	return c.Status(http.StatusOK).JSON(fiber.Map{
-// 		"message":     "Booking and Room Booking updated successfully",
-// 		"booking":     booking,
-// 		"roomBooking": roomBooking,
-// 	})
-// }
-
-
-// func UpdateBooking(c fiber.Ctx) error {
-// 	newBookingInfo := new(Booking)
-// 	newRoomBookingInfo := new(RoomBookings)
-// 	bookingID, _ := strconv.Atoi(c.Params("bookingId"))
-// 	roomBookingID, _ := strconv.Atoi(c.Params("roomBookingId"))
-
-// 	if err := c.Bind().JSON(&newBookingInfo); err != nil {
-// 		return c.Status(http.StatusBadRequest).JSON(err)
-// 	}
-
-// 	if err := c.Bind().JSON(&newRoomBookingInfo); err != nil {
-// 		return c.Status(http.StatusBadRequest).JSON(err)
-// 	}
-
-// 	booking := new(Booking)
-// 	booking.ID = uint(bookingID)
-
-// 	roomBooking := new(RoomBookings)
-// 	roomBooking.ID = uint(roomBookingID)
-
-// 	if result := storage.DB.Model(&booking).Updates(newBookingInfo); result.Error != nil {
-// 		return c.Status(http.StatusInternalServerError).SendString("Failed to update booking")
-// 	}
-
-// 	updates := map[string]interface{}{
-// 		"NumberOfNights": newRoomBookingInfo.NumberOfNights,
-// 		"CheckedIn":      newRoomBookingInfo.CheckedIn,
-// 		"CheckedOut":     newRoomBookingInfo.CheckedOut,
-// 		"StartDate":      newRoomBookingInfo.StartDate,
-// 		"RoomID":         newRoomBookingInfo.RoomID,
-// 		"EndDate":        newRoomBookingInfo.EndDate,
-// 	}
-
-// 	if result := storage.DB.Model(&roomBooking).Updates(updates); result.Error != nil {
-// 		return c.Status(http.StatusInternalServerError).SendString("Failed to update room booking")
-// 	}
-
-// 	return c.Status(http.StatusOK).JSON(fiber.Map{
-// 		"message":     "Booking and Room Booking updated successfully",
-// 		"booking":     booking,
-// 		"roomBooking": roomBooking,
-// 	})
-// }
-
-func CheckIn(c fiber.Ctx) error {
+func (h *Handler) CheckIn(c fiber.Ctx) error {
 	roomBookingId, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fmt.Errorf("invalid customer id"))
+		return apierror.InvalidID("room booking")
 	}
 
-	roomBooking := new(RoomBookings)
-	roomBooking.ID = uint(roomBookingId)
-
-	updates := map[string]interface{}{
-		"CheckedIn":  true,
-		"CheckedOut": false,
+	roomBooking, err := h.bookings.By(rbac.UserID(c)).CheckIn(uint(roomBookingId))
+	if err != nil {
+		return err
 	}
 
-	if result := storage.DB.Model(RoomBookings{}).Where("id = ?", roomBookingId).Updates(updates); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
-	}
+	return c.Status(http.StatusOK).JSON(roomBooking)
+}
 
-	var updatedRoomBooking RoomBookings
+// CheckOut check a guest out of a room, refused while the booking's folio has an unpaid balance
+// unless a user with the checkout:override permission overrides it {params: [override]}
+func (h *Handler) CheckOut(c fiber.Ctx) error {
+	roomBookingId, err := strconv.Atoi(c.Params("id"))
 
-	if result := storage.DB.Raw("SELECT * FROM room_bookings WHERE id = ?", roomBookingId).Find(&updatedRoomBooking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if err != nil {
+		return apierror.InvalidID("room booking")
 	}
 
-	roomUpdates := map[string]interface{}{
-		"Status": "Unavailable",
+	var override *CheckOutOverride
+	if c.Query("override") == "true" {
+		override = &CheckOutOverride{By: rbac.UserID(c), Allowed: rbac.Allowed(c, rbac.CheckOutOverride)}
 	}
 
-	roomId := updatedRoomBooking.RoomID
-
-	if result := storage.DB.Model(Room{}).Where("id = ?", roomId).Updates(roomUpdates); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	roomBooking, err := h.bookings.By(rbac.UserID(c)).CheckOut(uint(roomBookingId), override)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(updatedRoomBooking)
+	return c.Status(http.StatusOK).JSON(roomBooking)
 }
 
-func CheckOut(c fiber.Ctx) error {
+// NoShow mark a reserved stay whose guest never arrived as a no show
+func (h *Handler) NoShow(c fiber.Ctx) error {
 	roomBookingId, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fmt.Errorf("invalid customer id"))
+		return apierror.InvalidID("room booking")
 	}
 
-	roomBooking := new(RoomBookings)
-	roomBooking.ID = uint(roomBookingId)
-
-	updates := map[string]interface{}{
-		"CheckedIn":  false,
-		"CheckedOut": true,
+	roomBooking, err := h.bookings.By(rbac.UserID(c)).NoShow(uint(roomBookingId))
+	if err != nil {
+		return err
 	}
 
-	if result := storage.DB.Model(RoomBookings{}).Where("id = ?", roomBookingId).Updates(updates); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
-	}
+	return c.Status(http.StatusOK).JSON(roomBooking)
+}
 
-	var updatedRoomBooking RoomBookings
+type ExtendStayRequest struct {
+	NumberOfNights uint `json:"numberOfNights" validate:"min=1"`
+}
 
-	if result := storage.DB.Raw("SELECT * FROM room_bookings WHERE id = ?", roomBookingId).Find(&updatedRoomBooking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
-	}
+// ExtendStay add nights to the end of a room booking {body: [numberOfNights]}
+func (h *Handler) ExtendStay(c fiber.Ctx) error {
+	roomBookingId, err := strconv.Atoi(c.Params("id"))
 
-	roomUpdates := map[string]interface{}{
-		"Status": "available",
+	if err != nil {
+		return apierror.InvalidID("room booking")
 	}
 
-	roomId := updatedRoomBooking.RoomID
+	extendRequest := new(ExtendStayRequest)
 
-	if result := storage.DB.Model(Room{}).Where("id = ?", roomId).Updates(roomUpdates); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if err = c.Bind().JSON(extendRequest); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	roomBooking, err := h.bookings.By(rbac.UserID(c)).Extend(uint(roomBookingId), extendRequest.NumberOfNights)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(updatedRoomBooking)
+	return c.Status(http.StatusOK).JSON(roomBooking)
 }
 
-// func ViewSingleRoomBooking(c fiber.Ctx) error {
-// 	bookingID := c.Params("bookingID")
-// 	roomBookingID := c.Params("roomBookingID")
+// MoveRoom move a guest to another room from a night of their stay {body: [roomID, date, amount]}
+func (h *Handler) MoveRoom(c fiber.Ctx) error {
+	roomBookingId, err := strconv.Atoi(c.Params("id"))
 
-// 	if bookingID == "" {
-// 		return c.Status(http.StatusBadRequest).SendString("booking id not found")
-// 	}
+	if err != nil {
+		return apierror.InvalidID("room booking")
+	}
 
-// 	if roomBookingID == "" {
-// 		return c.Status(http.StatusBadRequest).SendString("roomBooking id not found")
-// 	}
+	moveRequest := new(MoveRoomRequest)
 
-// 	var roomBooking RoomBookings
-// 	if result := storage.DB.Where("booking_id = ? AND id = ?", bookingID, roomBookingID).First(&roomBooking); result.Error != nil {
-// 		if result.Error == gorm.ErrRecordNotFound {
-// 			return c.Status(http.StatusNotFound).SendString("room booking not found")
-// 		}
-// 		return c.Status(http.StatusInternalServerError).SendString("can't get room booking")
-// 	}
+	if err = c.Bind().JSON(moveRequest); err != nil {
+		return apierror.InvalidBody(err)
+	}
 
-// 	var booking Booking
-// 	if result := storage.DB.Where("id = ?", bookingID).First(&booking); result.Error != nil {
-// 		if result.Error == gorm.ErrRecordNotFound {
-// 			return c.Status(http.StatusNotFound).SendString("room booking not found")
-// 		}
-// 		return c.Status(http.StatusInternalServerError).SendString("can't get room booking")
-// 	}
+	move, err := h.bookings.By(rbac.UserID(c)).Move(uint(roomBookingId), *moveRequest)
+	if err != nil {
+		return err
+	}
 
-// 	booking.RoomBookings = append(booking.RoomBookings, &roomBooking)
-// 	return c.Status(http.StatusOK).JSON(fiber.Map{
-// 		"bookingData": booking,
-// 	})
-// }
+	return c.Status(http.StatusOK).JSON(move)
+}
 
-func ViewSingleRoomBooking(c fiber.Ctx) error {
-	bookingID := c.Params("bookingId")
-	roomBookingID := c.Params("roomBookingId")
+func (h *Handler) ViewSingleRoomBooking(c fiber.Ctx) error {
+	bookingID, err := strconv.Atoi(c.Params("bookingId"))
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
 
-	if bookingID == "" {
-		return c.Status(http.StatusInternalServerError).SendString("booking id not found")
+	roomBookingID, err := strconv.Atoi(c.Params("roomBookingId"))
+	if err != nil {
+		return apierror.InvalidID("room booking")
 	}
 
-	if roomBookingID == "" {
-		return c.Status(http.StatusInternalServerError).SendString("roomBooking id not found")
+	roomBooking, err := h.store.Bookings().FindRoomBookingOf(uint(bookingID), uint(roomBookingID))
+	if err != nil {
+		return err
 	}
-	var roomBooking RoomBookings
-	if result := storage.DB.Where("booking_id = ? AND id = ?", bookingID, roomBookingID).First(&roomBooking); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).SendString("can't get room booking")
+
+	if roomBooking.ID == 0 {
+		return apierror.NotFound("room booking")
 	}
 
 	return c.Status(http.StatusOK).JSON(roomBooking)
 }
 
-func DeleteBooking(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) DeleteBooking(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid booking id")
+	if err != nil {
+		return apierror.InvalidID("booking")
 	}
 
-	if result := storage.DB.Where("id = ?", id).Delete(&Booking{}); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if err = h.bookings.By(rbac.UserID(c)).Delete(uint(id)); err != nil {
+		return err
 	}
 
 	return c.SendStatus(http.StatusNoContent)
 }
 
 // GetBookingSummary {params [start, end]}
-// get booking summary for a particular date range (money_made, no_of_bookings, check_in, check_out, no_of_available_rooms, by_cash, by_pos, by_transfer)
-func GetBookingSummary(c fiber.Ctx) error {
-
-	start := c.Query("start")
-	end := c.Query("end")
-
-	var params []any
-	var whereClause strings.Builder
-
-	whereClause.WriteString("deleted_at is ? ")
-	params = append(params, nil)
-
-	if start != "" {
-		if len(params) != 0 {
-			whereClause.WriteString("AND ")
-		}
-		whereClause.WriteString("created_at >= ? ")
-		params = append(params, start)
-	}
-
-	if end != "" {
-		if len(params) != 0 {
-			whereClause.WriteString("AND ")
-		}
-		whereClause.WriteString("created_at <= ? ")
-		params = append(params, end)
-	}
-
-	sqlString := getSummaryQuery
-	if whereClause.Len() > 0 {
-		sqlString = strings.Replace(sqlString,
-			"select * from bookings",
-			fmt.Sprintf("select * from bookings where %s ", whereClause.String()),
-			1)
-	}
-
-	var sumAmount, numberOfBookings, sumAmountCash, sumAmountPos, sumAmountTransfer float64
-	var checkIn, checkOut, availableRooms uint
-
-	row := storage.DB.Raw(sqlString, params...).Row()
-	err := row.Scan(&sumAmount, &numberOfBookings, &sumAmountCash, &sumAmountPos, &sumAmountTransfer, &checkIn, &checkOut, &availableRooms)
+// get booking summary for a particular date range (money_made, no_of_bookings, check_in, check_out, no_of_available_rooms, by payment method)
+func (h *Handler) GetBookingSummary(c fiber.Ctx) error {
+	summary, err := h.store.Bookings().Summary(c.Query("start"), c.Query("end"), h.bookings.Now())
 	if err != nil {
-		log.Println(err)
-		return c.Status(http.StatusInternalServerError).JSON(err)
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"sumAmount":         sumAmount,
-		"numberOfBookings":  numberOfBookings,
-		"sumAmountCash":     sumAmountCash,
-		"sumAmountPos":      sumAmountPos,
-		"sumAmountTransfer": sumAmountTransfer,
-		"checkIn":           checkIn,
-		"checkOut":          checkOut,
+		"sumAmount":         summary.SumAmount,
+		"numberOfBookings":  summary.NumberOfBookings,
+		"byPaymentMethod":   summary.ByPaymentMethod,
+		"sumAmountCash":     summary.ByPaymentMethod["cash"],
+		"sumAmountPos":      summary.ByPaymentMethod["credit card"],
+		"sumAmountTransfer": summary.ByPaymentMethod["transfer"],
+		"checkIn":           summary.CheckIn,
+		"checkOut":          summary.CheckOut,
+		"availableRooms":    summary.AvailableRooms,
 	})
 }
 
 // BookRoom book a room
-func BookRoom(c fiber.Ctx) error {
+func (h *Handler) BookRoom(c fiber.Ctx) error {
 	bookRoomRequest := new(Booking)
 
 	if err := c.Bind().JSON(bookRoomRequest); err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(err)
-	}
-
-	totalAmount := 0.0
-
-	// check if the scheduled booking doesn't clash with another room booking
-	for _, roomBooking := range bookRoomRequest.RoomBookings {
-		// find room by id
-		r := Room{
-			Model: gorm.Model{
-				ID: roomBooking.RoomID,
-			},
-		}
-
-		if result := storage.DB.Find(&r); result.Error != nil {
-			return c.Status(http.StatusInternalServerError).JSON(result.Error)
-		}
-
-		if r.ID == 0 {
-			return c.Status(http.StatusInternalServerError).SendString("invalid room id")
-		}
-
-		var start time.Time
-		var end time.Time
-		var err error
-
-		if roomBooking.StartDate.IsZero() {
-			start, err = time.Parse(time.DateOnly, time.Now().UTC().Format(time.DateOnly))
-			start = start.Add(12 * time.Hour)
-			end = start.AddDate(0, 0, int(roomBooking.NumberOfNights))
-		} else {
-			start = roomBooking.StartDate.Add(12 * time.Hour)
-			end = start.AddDate(0, 0, int(roomBooking.NumberOfNights))
-		}
-
-		if err != nil {
-			return c.Status(http.StatusInternalServerError).JSON(err)
-		}
-
-		// get the individual dates
-		nights := []time.Time{start.Add(-12 * time.Hour)}
-
-		for i := uint(1); i < roomBooking.NumberOfNights; i++ {
-			nights = append(nights, start.AddDate(0, 0, int(i)))
-		}
-
-		for _, night := range nights {
-			dates, err1 := getBookedDatesByRoomID(roomBooking.RoomID)
-			if err1 != nil {
-				return c.Status(http.StatusInternalServerError).SendString(err1.Error())
-			}
-
-			if slices.Contains(dates, night) {
-				year, month, day := night.Date()
-				return c.Status(http.StatusBadRequest).SendString(fmt.Sprintf("room number %s is booked on %d/%d/%d", *r.Name, day, month, year))
-			}
-		}
-
-		roomBooking.StartDate = start
-		roomBooking.EndDate = end
-
-		// i.e is null
-		if roomBooking.Amount == nil {
-			a := r.Price
-			roomBooking.Amount = &a
-		}
-
-		totalAmount += *roomBooking.Amount * float64(roomBooking.NumberOfNights)
+		return apierror.InvalidBody(err)
 	}
 
-	bookRoomRequest.Amount = &totalAmount
-
-	if result := storage.DB.Create(bookRoomRequest); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if err := h.bookings.By(rbac.UserID(c)).Book(bookRoomRequest); err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(*bookRoomRequest)
 }
 
-// BookRoom book a room
-// func BookRoom(c fiber.Ctx) error {
-// 	bookRoomRequest := new(Booking)
-
-// 	if err := c.Bind().JSON(bookRoomRequest); err != nil {
-// 		return c.Status(http.StatusInternalServerError).JSON(err)
-// 	}
-
-// 	totalAmount := 0.0
-
-// 	// check if the scheduled booking doesn't clash with another room booking
-// 	for _, roomBooking := range bookRoomRequest.RoomBookings {
-// 		// find room by id
-// 		r := Room{
-// 			Model: gorm.Model{
-// 				ID: roomBooking.RoomID,
-// 			},
-// 		}
-
-// 		if result := storage.DB.Find(&r); result.Error != nil {
-// 			return c.Status(http.StatusInternalServerError).JSON(result.Error)
-// 		}
-
-// 		if r.ID == 0 {
-// 			return c.Status(http.StatusInternalServerError).SendString("invalid room id")
-// 		}
-
-// 		var start time.Time
-// 		var end time.Time
-// 		var err error
-
-// 		if roomBooking.StartDate.IsZero() {
-// 			start, err = time.Parse(time.DateOnly, time.Now().UTC().Format(time.DateOnly))
-// 			start = start.Add(12 * time.Hour)
-// 			end = start.AddDate(0, 0, int(roomBooking.NumberOfNights))
-// 		} else {
-// 			start = roomBooking.StartDate.Add(12 * time.Hour)
-// 			end = start.AddDate(0, 0, int(roomBooking.NumberOfNights))
-// 		}
-
-// 		if err != nil {
-// 			return c.Status(http.StatusInternalServerError).JSON(err)
-// 		}
-
-// 		// get the individual dates
-// 		nights := []time.Time{start.Add(-12 * time.Hour)}
-
-// 		for i := uint(1); i < roomBooking.NumberOfNights; i++ {
-// 			nights = append(nights, start.AddDate(0, 0, int(i)))
-// 		}
-
-// 		for _, night := range nights {
-// 			dates, err1 := getBookedDatesByRoomID(roomBooking.RoomID)
-// 			if err1 != nil {
-// 				return c.Status(http.StatusInternalServerError).SendString(err1.Error())
-// 			}
-
-// 			if slices.Contains(dates, night) {
-// 				year, month, day := night.Date()
-// 				return c.Status(http.StatusBadRequest).SendString(fmt.Sprintf("room number %s is booked on %d/%d/%d", *r.Name, day, month, year))
-// 			}
-// 		}
-
-// 		roomBooking.StartDate = start
-// 		roomBooking.EndDate = end
-
-// 		// i.e is null
-// 		if roomBooking.Amount == nil {
-// 			a := r.Price
-// 			roomBooking.Amount = &a
-// 		}
-
-// 		totalAmount += *roomBooking.Amount * float64(roomBooking.NumberOfNights)
-// 	}
-
-// 	bookRoomRequest.Amount = &totalAmount
-
-// 	if result := storage.DB.Create(bookRoomRequest); result.Error != nil {
-// 		return c.Status(http.StatusInternalServerError).JSON(result.Error)
-// 	}
-
-// 	return c.Status(http.StatusOK).JSON(*bookRoomRequest)
-// }
-
-func GetBookedDates(c fiber.Ctx) error {
+// GetBookedDates every night a room can't be booked, the nights it is booked and the nights it is out of service
+func (h *Handler) GetBookedDates(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).SendString("invalid room id")
+		return apierror.InvalidID("room")
 	}
 
-	dates, err := getBookedDatesByRoomID(uint(id))
+	dates, err := unavailableNights(h.store, uint(id))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(err)
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(dates)
 }
 
-func getBookedDatesByRoomID(roomID uint) ([]time.Time, error) {
-	rows, err := storage.DB.Raw(getBookedDatesByRoomIDQuery, roomID).Rows()
-	if err != nil {
-		return []time.Time{}, err
-	}
-	defer rows.Close()
-
-	var bookedDates []time.Time
-	for rows.Next() {
-		var a string
-		err1 := rows.Scan(&a)
-		if err1 != nil {
-			return []time.Time{}, err1
-		}
-
-		if b, err2 := time.Parse(time.DateOnly, a); err2 == nil {
-			bookedDates = append(bookedDates, b)
-		}
-	}
-
-	return bookedDates, nil
-}
-
 type BookRoomRequest struct {
 	CustomerID      *uint  `json:"customerID"`
 	Receptionist    uint   `json:"receptionist"`
@@ -679,109 +335,50 @@ type BookRoomRequest struct {
 }
 
 const (
+	// getSummaryQuery the %s are filled with the bookings where clause and the check in and check out date filters
 	getSummaryQuery = `
 	with b1 as (
-    	select * from bookings
+    	select * from bookings where %s
 	)
 
-	select 
+	select
     	(
         	select coalesce(sum(amount),0) from b1
     	) as sum_amount,
     	(
-        	select count(amount) from b1
+        	select count(*) from b1
     	) as  no_of_bookings,
     	(
-        	select coalesce(sum(amount),0) from b1 where payment_method == 'Cash'
-        ) as sum_amount_cash,
-    	(
-        	select coalesce(sum(amount),0) from b1 where payment_method == 'Credit Card'
-    	) as sum_amount_pos,
-    	(
-        	select coalesce(sum(amount),0) from b1 where payment_method == 'Transfer'
-    	) as sum_amount_transfer,
-    	(
-        	select count(*) from room_bookings where checked_in is true
-    	) as num_check_ins_today,
+        	select count(*) from room_bookings where deleted_at is null and status in ('checked_in', 'checked_out') %s
+    	) as num_check_ins,
     	(
-        	select count(*) from room_bookings where checked_out is true
-    	) as num_check_outs_today,
+        	select count(*) from room_bookings where deleted_at is null and status = 'checked_out' %s
+    	) as num_check_outs,
     	(
-        	select count(*) from rooms where id not in (
-            	select room_id from room_bookings where start_date <= datetime() and end_date >= datetime() and checked_in is true
+        	select count(*) from rooms where deleted_at is null and id not in (
+            	select room_id from room_bookings where deleted_at is null and start_date <= ? and end_date >= ? and status = 'checked_in'
             	)
     	) as num_available_rooms_today
 	`
 
-	getBookedDatesByRoomIDQuery = `
-	with recursive list(d1, d2, num_nights) as (
-    select
-        date(start_date) as d1,
-        date(end_date) as d2,
-        1 AS num_nights
-    from room_bookings where checked_out is false and room_id == ?
-    union
-    select
-        date(d1, format('+%d days', 1)),
-        d2,
-        num_nights + 1 from list
-    where date(d1, format('+%d days', 1)) < d2
-	)
+	// getSummaryByPaymentMethodQuery booking amounts for every payment method in use, %s is the bookings where clause
+	getSummaryByPaymentMethodQuery = `
+	select lower(payment_method), coalesce(sum(amount),0) from bookings where %s group by lower(payment_method)
+	`
 
-	select d1 as booked_dates from list;
+	// getAvailableRoomsQuery rooms with no booked night between two dates, filters are appended by GetAvailableRooms
+	getAvailableRoomsQuery = `
+	select * from rooms
+	where deleted_at is null and id not in (
+		select rb.room_id from room_bookings rb
+		join bookings b on b.id = rb.booking_id
+		where rb.status in ('reserved', 'checked_in')
+			and rb.deleted_at is null and b.deleted_at is null and b.status != 'cancelled'
+			and date(rb.start_date) < ? and date(rb.end_date) > ?
+	) and id not in (
+		select mb.room_id from maintenance_blocks mb
+		where mb.closed_at is null and mb.deleted_at is null
+			and date(mb.start_date) < ? and date(mb.end_date) >= ?
+	)
 	`
 )
-
-// const (
-// 	getSummaryQuery = `
-// 	with b1 as (
-//     	select * from bookings
-// 	)
-
-// 	select 
-//     	(
-//         	select coalesce(sum(amount),0) from b1
-//     	) as sum_amount,
-//     	(
-//         	select count(amount) from b1
-//     	) as  no_of_bookings,
-//     	(
-//         	select coalesce(sum(amount),0) from b1 where payment_method == 'Cash'
-//         ) as sum_amount_cash,
-//     	(
-//         	select coalesce(sum(amount),0) from b1 where payment_method == 'Credit Card'
-//     	) as sum_amount_pos,
-//     	(
-//         	select coalesce(sum(amount),0) from b1 where payment_method == 'Transfer'
-//     	) as sum_amount_transfer,
-//     	(
-//         	select count(*) from room_bookings where start_date <= datetime() and end_date >= datetime() and checked_in is true
-//     	) as num_check_ins_today,
-//     	(
-//         	select count(*) from room_bookings where date(end_date) == date() and checked_out is true
-//     	) as num_check_outs_today,
-//     	(
-//         	select count(*) from rooms where id not in (
-//             	select room_id from room_bookings where start_date <= datetime() and end_date >= datetime() and checked_in is true
-//             	)
-//     	) as num_available_rooms_today
-// 	`
-
-// 	getBookedDatesByRoomIDQuery = `
-// 	with recursive list(d1, d2, num_nights) as (
-//     select
-//         date(start_date) as d1,
-//         date(end_date) as d2,
-//         1 AS num_nights
-//     from room_bookings where checked_out is false and room_id == ?
-//     union
-//     select
-//         date(d1, format('+%d days', 1)),
-//         d2,
-//         num_nights + 1 from list
-//     where date(d1, format('+%d days', 1)) < d2
-// 	)
-
-// 	select d1 as booked_dates from list;
-// 	`
-// )
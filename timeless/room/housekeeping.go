@@ -0,0 +1,336 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"gorm.io/gorm"
+)
+
+// the housekeeping status of a room. a guest can only be checked into an inspected room, checking them out
+// leaves it dirty and opens a cleaning task
+const (
+	RoomStatusVacantClean = "vacant_clean"
+	RoomStatusVacantDirty = "vacant_dirty"
+	RoomStatusOccupied    = "occupied"
+	RoomStatusInspected   = "inspected"
+	RoomStatusOutOfOrder  = "out_of_order"
+)
+
+var RoomStatuses = []string{
+	RoomStatusVacantClean,
+	RoomStatusVacantDirty,
+	RoomStatusOccupied,
+	RoomStatusInspected,
+	RoomStatusOutOfOrder,
+}
+
+// roomTransitions the statuses housekeeping can move a room to from each status. occupied is only entered
+// by checking a guest in and only left by checking them out
+var roomTransitions = map[string][]string{
+	RoomStatusVacantDirty: {RoomStatusVacantClean, RoomStatusOutOfOrder},
+	RoomStatusVacantClean: {RoomStatusInspected, RoomStatusVacantDirty, RoomStatusOutOfOrder},
+	RoomStatusInspected:   {RoomStatusVacantDirty, RoomStatusOutOfOrder},
+	RoomStatusOutOfOrder:  {RoomStatusVacantDirty},
+}
+
+// BeforeCreate a new room waits to be inspected like any other
+func (r *Room) BeforeCreate(tx *gorm.DB) error {
+	if r.Status == nil {
+		status := RoomStatusVacantClean
+		r.Status = &status
+	}
+
+	return nil
+}
+
+// status the housekeeping status of a room
+func (r Room) status() string {
+	if r.Status == nil {
+		return ""
+	}
+
+	return *r.Status
+}
+
+// the kinds of housekeeping task
+const (
+	TaskTypeCheckout  = "checkout"   // clean a room after the guest left, opened by CheckOut
+	TaskTypeStayover  = "stayover"   // clean a room the guest is still staying in
+	TaskTypeDeepClean = "deep_clean" // a thorough clean, e.g. before a room goes back into service
+)
+
+var TaskTypes = []string{TaskTypeCheckout, TaskTypeStayover, TaskTypeDeepClean}
+
+// a task is open until someone claims it, then claimed until they complete it
+const (
+	TaskStatusOpen    = "open"
+	TaskStatusClaimed = "claimed"
+	TaskStatusDone    = "done"
+)
+
+var TaskStatuses = []string{TaskStatusOpen, TaskStatusClaimed, TaskStatusDone}
+
+// HousekeepingTask a room to be cleaned. housekeeping staff claim a task, then complete it once the room is clean
+type HousekeepingTask struct {
+	gorm.Model
+	RoomID        uint       `json:"roomID" gorm:"index" validate:"required"`
+	RoomBookingID *uint      `json:"roomBookingID"` // the stay that was checked out, for checkout tasks
+	Type          string     `json:"type" validate:"required,oneof=checkout stayover deep_clean"`
+	Status        string     `json:"status" gorm:"index"`
+	Notes         *string    `json:"notes"`
+	AssignedTo    *uint      `json:"assignedTo"`
+	ClaimedAt     *time.Time `json:"claimedAt"`
+	CompletedAt   *time.Time `json:"completedAt"`
+}
+
+// HousekeepingService the housekeeping rules: the room status state machine, the cleaning tasks and the
+// maintenance blocks that take rooms out of service. every change is recorded in the audit log in its own transaction
+type HousekeepingService struct {
+	store Store
+	// actor the user the changes are recorded against, 0 for the system
+	actor uint
+	// Now the current time, replaceable so the rules can be run at a fixed time
+	Now func() time.Time
+}
+
+// NewHousekeepingService a HousekeepingService working through store
+func NewHousekeepingService(store Store) *HousekeepingService {
+	return &HousekeepingService{
+		store: store,
+		Now:   func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// By a copy of the service that records its changes as made by the user actor
+func (s *HousekeepingService) By(actor uint) *HousekeepingService {
+	service := *s
+	service.actor = actor
+	return &service
+}
+
+// SetRoomStatus move a room to status, only along roomTransitions. inspecting a room needs canInspect
+func (s *HousekeepingService) SetRoomStatus(roomID uint, status string, canInspect bool) (Room, error) {
+	var r Room
+
+	if !slices.Contains(RoomStatuses, status) {
+		return r, apierror.Field("status", "status must be one of "+strings.Join(RoomStatuses, ", "))
+	}
+
+	if status == RoomStatusOccupied {
+		return r, apierror.Field("status", "a room becomes occupied by checking a guest in")
+	}
+
+	if status == RoomStatusInspected && !canInspect {
+		return r, apierror.Forbidden("only a manager can pass a room as inspected")
+	}
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if r, err = tx.Rooms().Lock(roomID); err != nil {
+			return err
+		}
+
+		if r.ID == 0 {
+			return apierror.NotFound("room")
+		}
+
+		if r.status() == status {
+			return nil
+		}
+
+		if !slices.Contains(roomTransitions[r.status()], status) {
+			return apierror.Conflict(fmt.Sprintf("room %s can't go from %s to %s", roomName(r), r.status(), status))
+		}
+
+		r, err = moveRoom(tx, s.actor, r, status)
+		return err
+	})
+
+	return r, err
+}
+
+// CreateTask open a task for a room
+func (s *HousekeepingService) CreateTask(task *HousekeepingTask) error {
+	return s.store.Transaction(func(tx Store) error {
+		r, err := tx.Rooms().Find(task.RoomID)
+		if err != nil {
+			return err
+		}
+
+		if r.ID == 0 {
+			return apierror.Field("roomID", fmt.Sprintf("room %d does not exist", task.RoomID))
+		}
+
+		return openTask(tx, s.actor, task)
+	})
+}
+
+// Claim take an open task, it is assigned to the service's user
+func (s *HousekeepingService) Claim(taskID uint) (HousekeepingTask, error) {
+	var task HousekeepingTask
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if task, err = tx.Tasks().Lock(taskID); err != nil {
+			return err
+		}
+
+		if task.ID == 0 {
+			return apierror.NotFound("task")
+		}
+
+		if task.Status != TaskStatusOpen {
+			return apierror.Conflict("task has already been " + task.Status)
+		}
+
+		before := task
+
+		now := s.Now()
+		actor := s.actor
+		task.Status = TaskStatusClaimed
+		task.AssignedTo = &actor
+		task.ClaimedAt = &now
+
+		updates := map[string]any{
+			"Status":     task.Status,
+			"AssignedTo": task.AssignedTo,
+			"ClaimedAt":  task.ClaimedAt,
+		}
+
+		if err = tx.Tasks().Update(task.ID, updates); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(s.actor, audit.ActionClaim, audit.EntityHousekeepingTask, task.ID, before, task)
+	})
+
+	return task, err
+}
+
+// Complete finish a claimed task, a dirty room becomes clean. only whoever claimed it can complete it unless anyone is set
+func (s *HousekeepingService) Complete(taskID uint, notes *string, anyone bool) (HousekeepingTask, error) {
+	var task HousekeepingTask
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if task, err = tx.Tasks().Lock(taskID); err != nil {
+			return err
+		}
+
+		if task.ID == 0 {
+			return apierror.NotFound("task")
+		}
+
+		if task.Status != TaskStatusClaimed {
+			return apierror.Conflict(fmt.Sprintf("only a claimed task can be completed, this one is %s", task.Status))
+		}
+
+		if !anyone && (task.AssignedTo == nil || *task.AssignedTo != s.actor) {
+			return apierror.Forbidden("the task is claimed by someone else")
+		}
+
+		before := task
+
+		now := s.Now()
+		task.Status = TaskStatusDone
+		task.CompletedAt = &now
+
+		updates := map[string]any{
+			"Status":      task.Status,
+			"CompletedAt": task.CompletedAt,
+		}
+
+		if notes != nil {
+			task.Notes = notes
+			updates["Notes"] = task.Notes
+		}
+
+		if err = tx.Tasks().Update(task.ID, updates); err != nil {
+			return err
+		}
+
+		if err = tx.Audit().Record(s.actor, audit.ActionComplete, audit.EntityHousekeepingTask, task.ID, before, task); err != nil {
+			return err
+		}
+
+		r, err := tx.Rooms().Lock(task.RoomID)
+		if err != nil {
+			return err
+		}
+
+		// a stayover clean leaves the room occupied, it still needs inspecting before the next guest
+		if r.ID == 0 || r.status() != RoomStatusVacantDirty {
+			return nil
+		}
+
+		_, err = moveRoom(tx, s.actor, r, RoomStatusVacantClean)
+		return err
+	})
+
+	return task, err
+}
+
+// moveRoom change the status of room r, recording it as done by actor
+func moveRoom(tx Store, actor uint, r Room, status string) (Room, error) {
+	if err := tx.Rooms().SetStatus(r.ID, status); err != nil {
+		return r, err
+	}
+
+	moved := r
+	moved.Status = &status
+
+	return moved, tx.Audit().Record(actor, audit.ActionChangeStatus, audit.EntityRoom, r.ID, r, moved)
+}
+
+// leaveRoom the guest of a room booking left: the room is dirty and needs cleaning, unless it already has a task waiting
+func leaveRoom(tx Store, actor uint, roomBooking RoomBookings) error {
+	r, err := tx.Rooms().Lock(roomBooking.RoomID)
+	if err != nil {
+		return err
+	}
+
+	if r.status() != RoomStatusVacantDirty {
+		if _, err = moveRoom(tx, actor, r, RoomStatusVacantDirty); err != nil {
+			return err
+		}
+	}
+
+	unfinished, err := tx.Tasks().Unfinished(r.ID)
+	if err != nil || len(unfinished) > 0 {
+		return err
+	}
+
+	return openTask(tx, actor, &HousekeepingTask{
+		RoomID:        r.ID,
+		RoomBookingID: &roomBooking.ID,
+		Type:          TaskTypeCheckout,
+	})
+}
+
+// openTask save a new open task, recording it as opened by actor
+func openTask(tx Store, actor uint, task *HousekeepingTask) error {
+	task.Status = TaskStatusOpen
+	task.AssignedTo = nil
+	task.ClaimedAt = nil
+	task.CompletedAt = nil
+
+	if err := tx.Tasks().Create(task); err != nil {
+		return err
+	}
+
+	return tx.Audit().Record(actor, audit.ActionCreate, audit.EntityHousekeepingTask, task.ID, nil, task)
+}
+
+// roomName the name of a room for messages, its id when it has none
+func roomName(r Room) string {
+	if r.Name == nil {
+		return fmt.Sprint(r.ID)
+	}
+
+	return *r.Name
+}
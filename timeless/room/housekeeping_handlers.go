@@ -0,0 +1,161 @@
+package room
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+type SetRoomStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+// SetRoomStatus move a room through housekeeping {body: [status]}, only managers can pass a room as inspected
+func (h *Handler) SetRoomStatus(c fiber.Ctx) error {
+	roomID, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("room")
+	}
+
+	request := new(SetRoomStatusRequest)
+
+	if err = c.Bind().JSON(request); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	room, err := h.housekeeping.By(rbac.UserID(c)).SetRoomStatus(uint(roomID), strings.ToLower(request.Status), rbac.Allowed(c, rbac.RoomInspect))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(room)
+}
+
+// GetTasks {params: [status, type, roomId, assignedTo, page, page_size, sort, order]} oldest first by default
+func (h *Handler) GetTasks(c fiber.Ctx) error {
+	filter := TaskFilter{
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+	}
+
+	if filter.Status != "" && !slices.Contains(TaskStatuses, filter.Status) {
+		return apierror.Field("status", "status must be one of "+strings.Join(TaskStatuses, ", "))
+	}
+
+	if filter.Type != "" && !slices.Contains(TaskTypes, filter.Type) {
+		return apierror.Field("type", "type must be one of "+strings.Join(TaskTypes, ", "))
+	}
+
+	if roomID := c.Query("roomId"); roomID != "" {
+		id, err := strconv.Atoi(roomID)
+		if err != nil {
+			return apierror.InvalidID("room")
+		}
+		filter.RoomID = uint(id)
+	}
+
+	if assignedTo := c.Query("assignedTo"); assignedTo != "" {
+		id, err := strconv.Atoi(assignedTo)
+		if err != nil {
+			return apierror.InvalidID("user")
+		}
+		filter.AssignedTo = uint(id)
+	}
+
+	page, err := pagination.FromQuery(c, TaskSorts, "createdAt", "asc")
+	if err != nil {
+		return err
+	}
+
+	tasks, total, err := h.store.Tasks().List(filter, page)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(pagination.New(tasks, total, page))
+}
+
+func (h *Handler) GetTaskById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("task")
+	}
+
+	task, err := h.store.Tasks().Find(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if task.ID == 0 {
+		return apierror.NotFound("task")
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
+
+// CreateTask open a housekeeping task for a room {body: [roomID, type, notes]}
+func (h *Handler) CreateTask(c fiber.Ctx) error {
+	task := new(HousekeepingTask)
+
+	if err := c.Bind().JSON(task); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	if err := h.housekeeping.By(rbac.UserID(c)).CreateTask(task); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(task)
+}
+
+// ClaimTask assign an open task to the authenticated user
+func (h *Handler) ClaimTask(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("task")
+	}
+
+	task, err := h.housekeeping.By(rbac.UserID(c)).Claim(uint(id))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
+
+type CompleteTaskRequest struct {
+	Notes *string `json:"notes"`
+}
+
+// CompleteTask finish a task the authenticated user claimed, managers can complete anyone's {body: [notes]}
+func (h *Handler) CompleteTask(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("task")
+	}
+
+	request := new(CompleteTaskRequest)
+
+	if len(c.Body()) > 0 {
+		if err = c.Bind().JSON(request); err != nil {
+			return apierror.InvalidBody(err)
+		}
+	}
+
+	task, err := h.housekeeping.By(rbac.UserID(c)).Complete(uint(id), request.Notes, rbac.Allowed(c, rbac.HousekeepingManage))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(task)
+}
@@ -0,0 +1,229 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"gorm.io/gorm"
+)
+
+// MaintenanceBlock a room taken out of service for repairs on every night from StartDate to EndDate, both included.
+// the nights can't be booked while the block is open, closing it puts the room back in service
+type MaintenanceBlock struct {
+	gorm.Model
+	RoomID     uint       `json:"roomID" gorm:"index"`
+	StartDate  time.Time  `json:"startDate" validate:"required"`
+	EndDate    time.Time  `json:"endDate" validate:"required,notbefore=StartDate"`
+	Reason     string     `json:"reason" validate:"required"`
+	AssignedTo *uint      `json:"assignedTo"` // who is fixing the room
+	ClosedAt   *time.Time `json:"closedAt"`
+	ClosedBy   *uint      `json:"closedBy"`
+}
+
+// BlockUpdate the fields of a maintenance block to change, nil ones stay as they are
+type BlockUpdate struct {
+	StartDate  *time.Time `json:"startDate"`
+	EndDate    *time.Time `json:"endDate"`
+	Reason     *string    `json:"reason"`
+	AssignedTo *uint      `json:"assignedTo"`
+}
+
+// nights every night the block takes the room out of service
+func (b MaintenanceBlock) nights() []time.Time {
+	first, last := dateOnly(b.StartDate), dateOnly(b.EndDate)
+	return stayNights(first, uint(last.Sub(first).Hours()/24)+1)
+}
+
+// OpenBlock take a room out of service for the nights of block. nights that are booked have to be moved first
+func (s *HousekeepingService) OpenBlock(roomID uint, block *MaintenanceBlock) error {
+	return s.store.Transaction(func(tx Store) error {
+		r, err := tx.Rooms().Lock(roomID)
+		if err != nil {
+			return err
+		}
+
+		if r.ID == 0 {
+			return apierror.NotFound("room")
+		}
+
+		block.RoomID = r.ID
+		block.StartDate = dateOnly(block.StartDate)
+		block.EndDate = dateOnly(block.EndDate)
+		block.ClosedAt = nil
+		block.ClosedBy = nil
+
+		if err = checkBlock(tx, r, *block); err != nil {
+			return err
+		}
+
+		if err = tx.Maintenance().Create(block); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(s.actor, audit.ActionCreate, audit.EntityMaintenanceBlock, block.ID, nil, block)
+	})
+}
+
+// UpdateBlock change the dates, reason or assignee of an open block
+func (s *HousekeepingService) UpdateBlock(roomID, blockID uint, update BlockUpdate) (MaintenanceBlock, error) {
+	var block MaintenanceBlock
+
+	err := s.store.Transaction(func(tx Store) error {
+		r, err := tx.Rooms().Lock(roomID)
+		if err != nil {
+			return err
+		}
+
+		if block, err = tx.Maintenance().Find(roomID, blockID); err != nil {
+			return err
+		}
+
+		if r.ID == 0 || block.ID == 0 {
+			return apierror.NotFound("maintenance block")
+		}
+
+		if block.ClosedAt != nil {
+			return apierror.Conflict("maintenance block has been closed")
+		}
+
+		before := block
+
+		if update.StartDate != nil {
+			block.StartDate = dateOnly(*update.StartDate)
+		}
+
+		if update.EndDate != nil {
+			block.EndDate = dateOnly(*update.EndDate)
+		}
+
+		if update.Reason != nil {
+			if *update.Reason == "" {
+				return apierror.Field("reason", "reason is required")
+			}
+			block.Reason = *update.Reason
+		}
+
+		if update.AssignedTo != nil {
+			block.AssignedTo = update.AssignedTo
+		}
+
+		if block.EndDate.Before(block.StartDate) {
+			return apierror.Field("endDate", "endDate must not be before startDate")
+		}
+
+		if err = checkBlock(tx, r, block); err != nil {
+			return err
+		}
+
+		updates := map[string]any{
+			"StartDate":  block.StartDate,
+			"EndDate":    block.EndDate,
+			"Reason":     block.Reason,
+			"AssignedTo": block.AssignedTo,
+		}
+
+		if err = tx.Maintenance().Update(block.ID, updates); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(s.actor, audit.ActionUpdate, audit.EntityMaintenanceBlock, block.ID, before, block)
+	})
+
+	return block, err
+}
+
+// CloseBlock put a room back in service, the rest of the block's nights can be booked again
+func (s *HousekeepingService) CloseBlock(roomID, blockID uint) (MaintenanceBlock, error) {
+	var block MaintenanceBlock
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if block, err = tx.Maintenance().Find(roomID, blockID); err != nil {
+			return err
+		}
+
+		if block.ID == 0 {
+			return apierror.NotFound("maintenance block")
+		}
+
+		if block.ClosedAt != nil {
+			return apierror.Conflict("maintenance block has already been closed")
+		}
+
+		before := block
+
+		now := s.Now()
+		actor := s.actor
+		block.ClosedAt = &now
+		block.ClosedBy = &actor
+
+		updates := map[string]any{
+			"ClosedAt": block.ClosedAt,
+			"ClosedBy": block.ClosedBy,
+		}
+
+		if err = tx.Maintenance().Update(block.ID, updates); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(s.actor, audit.ActionClose, audit.EntityMaintenanceBlock, block.ID, before, block)
+	})
+
+	return block, err
+}
+
+// checkBlock refuse a block over nights that are booked or already blocked by another block
+func checkBlock(tx Store, r Room, block MaintenanceBlock) error {
+	nights := block.nights()
+
+	booked, err := tx.Bookings().BookedNights(r.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, night := range nights {
+		if slices.Contains(booked, night) {
+			year, month, day := night.Date()
+			return apierror.Conflict(fmt.Sprintf("room number %s is booked on %d/%d/%d, move the booking first", roomName(r), day, month, year))
+		}
+	}
+
+	blocked, err := tx.Maintenance().BlockedNights(r.ID, block.ID)
+	if err != nil {
+		return err
+	}
+
+	return checkBlocked(r, blocked, nights)
+}
+
+// checkBlocked refuse nights room r is out of service
+func checkBlocked(r Room, blocked []time.Time, nights []time.Time) error {
+	for _, night := range nights {
+		if slices.Contains(blocked, night) {
+			year, month, day := night.Date()
+			return apierror.Conflict(fmt.Sprintf("room number %s is out of service on %d/%d/%d", roomName(r), day, month, year))
+		}
+	}
+
+	return nil
+}
+
+// unavailableNights every night a room is booked or out of service, in order
+func unavailableNights(store Store, roomID uint) ([]time.Time, error) {
+	booked, err := store.Bookings().BookedNights(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocked, err := store.Maintenance().BlockedNights(roomID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	nights := append(booked, blocked...)
+	slices.SortFunc(nights, time.Time.Compare)
+	return slices.CompactFunc(nights, time.Time.Equal), nil
+}
@@ -0,0 +1,107 @@
+package room
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+// blockParams read the :id and :blockId route parameters
+func blockParams(c fiber.Ctx) (uint, uint, error) {
+	roomID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, apierror.InvalidID("room")
+	}
+
+	blockID, err := strconv.Atoi(c.Params("blockId"))
+	if err != nil {
+		return 0, 0, apierror.InvalidID("maintenance block")
+	}
+
+	return uint(roomID), uint(blockID), nil
+}
+
+// GetBlocks the maintenance blocks of a room, closed ones included
+func (h *Handler) GetBlocks(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("room")
+	}
+
+	room, err := h.store.Rooms().Find(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if room.ID == 0 {
+		return apierror.NotFound("room")
+	}
+
+	blocks, err := h.store.Maintenance().ForRoom(room.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(blocks)
+}
+
+// OpenBlock take a room out of service {body: [startDate, endDate, reason, assignedTo]}
+func (h *Handler) OpenBlock(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("room")
+	}
+
+	block := new(MaintenanceBlock)
+
+	if err = c.Bind().JSON(block); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	if err = h.housekeeping.By(rbac.UserID(c)).OpenBlock(uint(id), block); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(block)
+}
+
+// UpdateBlock change an open maintenance block {body: [startDate, endDate, reason, assignedTo]}
+func (h *Handler) UpdateBlock(c fiber.Ctx) error {
+	roomID, blockID, err := blockParams(c)
+	if err != nil {
+		return err
+	}
+
+	update := new(BlockUpdate)
+
+	if err = c.Bind().JSON(update); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	block, err := h.housekeeping.By(rbac.UserID(c)).UpdateBlock(roomID, blockID, *update)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(block)
+}
+
+// CloseBlock put a room back in service
+func (h *Handler) CloseBlock(c fiber.Ctx) error {
+	roomID, blockID, err := blockParams(c)
+	if err != nil {
+		return err
+	}
+
+	block, err := h.housekeeping.By(rbac.UserID(c)).CloseBlock(roomID, blockID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(block)
+}
@@ -13,19 +13,56 @@ type Booking struct {
 	IsPaid          bool            `json:"isPaid"`
 	PaymentMethod   string          `json:"paymentMethod" validate:"required"`
 	IsComplementary bool            `json:"isComplementary" gorm:"default:false"`
-	RoomBookings    []*RoomBookings `json:"roomBookings" gorm:"constraint:OnUpdate:CASCADE,onDelete:CASCADE"`
+	RoomBookings    []*RoomBookings `json:"roomBookings" gorm:"constraint:OnUpdate:CASCADE,onDelete:CASCADE" validate:"required,min=1,dive"`
+
+	Status             string     `json:"status" gorm:"default:active"`
+	CancelledAt        *time.Time `json:"cancelledAt"`
+	CancelledBy        *uint      `json:"cancelledBy"`
+	CancellationReason *string    `json:"cancellationReason"`
+	CancellationFee    *float64   `json:"cancellationFee"`
+	RefundAmount       *float64   `json:"refundAmount"`
+
+	Payments []*Payment `json:"payments" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE" validate:"dive"`
+	Charges  []*Charge  `json:"charges" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	// ChargesTotal, AmountPaid and Balance are worked out from Charges and Payments by computeBalance, they are not stored
+	ChargesTotal float64 `json:"chargesTotal" gorm:"-"`
+	AmountPaid   float64 `json:"amountPaid" gorm:"-"`
+	Balance      float64 `json:"balance" gorm:"-"`
 }
 
+const (
+	BookingStatusActive    = "active"
+	BookingStatusCancelled = "cancelled"
+)
+
 type RoomBookings struct {
 	gorm.Model
-	NumberOfNights uint      `json:"numberOfNights" validate:"required"`
-	CheckedIn      bool      `json:"checkedIn" gorm:"default:false"`
-	CheckedOut     bool      `json:"checkedOut" gorm:"default:false"`
+	NumberOfNights uint      `json:"numberOfNights" validate:"min=1"`
+	Status         string    `json:"status" gorm:"default:reserved;index"` // one of StayStatuses
 	StartDate      time.Time `json:"startDate" validate:"required"`
-	EndDate        time.Time `json:"endDate" validate:"required"`
+	EndDate        time.Time `json:"endDate" validate:"omitempty,after=StartDate"` // worked out from NumberOfNights when booking
 	Amount         *float64  `json:"amount"`
 	BookingID      uint      `json:"bookingID"`
 	RoomID         uint      `json:"roomID"`
+
+	// CheckedInAt and CheckedOutAt when the guest really arrived and left
+	CheckedInAt          *time.Time `json:"checkedInAt"`
+	CheckedOutAt         *time.Time `json:"checkedOutAt"`
+	CheckOutOverriddenBy *uint      `json:"checkOutOverriddenBy"`
+	// MovedFromID the room booking this one was split from when the guest moved rooms
+	MovedFromID *uint `json:"movedFromID"`
+
+	Nights []*RoomBookingNight `json:"nights" gorm:"foreignKey:RoomBookingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// RoomBookingNight the price charged for a single night of a room booking
+type RoomBookingNight struct {
+	gorm.Model
+	RoomBookingID uint      `json:"roomBookingID"`
+	Date          time.Time `json:"date"`
+	Rate          float64   `json:"rate"`
+	RatePlanID    *uint     `json:"ratePlanID"`
 }
 
 type Room struct {
@@ -33,7 +70,63 @@ type Room struct {
 	Name         *string        `json:"name" validate:"required"`
 	Category     *string        `json:"category"`
 	Description  *string        `json:"description"`
-	Price        float64        `json:"price" validate:"required"`
-	Status       *string        `json:"status" gorm:"default:available"`
+	Price        float64        `json:"price" validate:"gt=0"`
+	Status       *string        `json:"status" gorm:"default:vacant_clean"`
 	RoomBookings []RoomBookings `json:"roomBookings"`
 }
+
+// Payment money received against a booking, a booking can be paid in several parts e.g. a deposit and the rest at checkout
+type Payment struct {
+	gorm.Model
+	BookingID    uint      `json:"bookingID"`
+	Amount       float64   `json:"amount" validate:"gt=0"`
+	Method       string    `json:"method" validate:"required"`
+	Reference    *string   `json:"reference"`
+	Receptionist uint      `json:"receptionist"`
+	PaidAt       time.Time `json:"paidAt"`
+}
+
+// Charge an incidental charge (minibar, laundry, restaurant, damage) posted to a booking's folio,
+// optionally against one of its room bookings
+type Charge struct {
+	gorm.Model
+	BookingID     uint    `json:"bookingID"`
+	RoomBookingID *uint   `json:"roomBookingID"`
+	Category      string  `json:"category" validate:"required"`
+	Description   *string `json:"description"`
+	Quantity      uint    `json:"quantity"`
+	UnitPrice     float64 `json:"unitPrice" validate:"gt=0"`
+	Amount        float64 `json:"amount"`
+	PostedBy      uint    `json:"postedBy"`
+}
+
+const (
+	ChargeCategoryMinibar    = "minibar"
+	ChargeCategoryLaundry    = "laundry"
+	ChargeCategoryRestaurant = "restaurant"
+	ChargeCategoryDamage     = "damage"
+	ChargeCategoryOther      = "other"
+)
+
+var ChargeCategories = []string{
+	ChargeCategoryMinibar,
+	ChargeCategoryLaundry,
+	ChargeCategoryRestaurant,
+	ChargeCategoryDamage,
+	ChargeCategoryOther,
+}
+
+// RatePlan a named price schedule. A plan applies to a single room when RoomID is set,
+// otherwise to every room in Category, otherwise to every room
+type RatePlan struct {
+	gorm.Model
+	Name          *string    `json:"name" validate:"required"`
+	RoomID        *uint      `json:"roomID"`
+	Category      *string    `json:"category"`
+	StartDate     *time.Time `json:"startDate"`
+	EndDate       *time.Time `json:"endDate" validate:"omitempty,notbefore=StartDate"`
+	Price         float64    `json:"price" validate:"gt=0"`
+	WeekendUplift float64    `json:"weekendUplift"` // percentage added on weekend nights
+	MinimumStay   uint       `json:"minimumStay"`
+	Priority      int        `json:"priority"`
+}
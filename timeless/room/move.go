@@ -0,0 +1,260 @@
+package room
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+)
+
+// MoveRoomRequest move the rest of a stay to another room from the night of Date
+type MoveRoomRequest struct {
+	RoomID uint       `json:"roomID" validate:"required"`
+	Date   *time.Time `json:"date"`   // the first night in the new room, today when not given
+	Amount *float64   `json:"amount"` // a flat nightly rate for the nights in the new room, the rate plans otherwise
+}
+
+// RoomMove the two parts of a stay split by a room move. From is nil when the whole stay moved
+type RoomMove struct {
+	From *RoomBookings `json:"from"`
+	To   RoomBookings  `json:"to"`
+}
+
+// Move move a guest to another room. the room booking is split at the move date: the nights before it stay
+// in the old room at the rates they were booked at, the rest go to a new room booking in the new room and are
+// priced again. a checked in guest moves on or before today, their old room is left dirty and the new one has
+// to have been inspected, it becomes occupied
+func (s *BookingService) Move(roomBookingID uint, request MoveRoomRequest) (RoomMove, error) {
+	var move RoomMove
+
+	err := s.store.Transaction(func(tx Store) error {
+		roomBooking, err := tx.Bookings().FindRoomBooking(roomBookingID)
+		if err != nil {
+			return err
+		}
+
+		if roomBooking.ID == 0 {
+			return apierror.NotFound("room booking")
+		}
+
+		if !roomBooking.holdsRoom() {
+			return apierror.Conflict(fmt.Sprintf("can't move a stay that is %s", roomBooking.Status))
+		}
+
+		booking, err := tx.Bookings().Find(roomBooking.BookingID)
+		if err != nil {
+			return err
+		}
+
+		if booking.Status == BookingStatusCancelled {
+			return apierror.Conflict("can't move a booking that has been cancelled")
+		}
+
+		if roomBooking.Nights, err = tx.Bookings().Nights(roomBooking.ID); err != nil {
+			return err
+		}
+
+		if request.RoomID == roomBooking.RoomID {
+			return apierror.Field("roomID", "the guest is already booked in this room")
+		}
+
+		from, err := tx.Rooms().Lock(roomBooking.RoomID)
+		if err != nil {
+			return err
+		}
+
+		to, err := tx.Rooms().Lock(request.RoomID)
+		if err != nil {
+			return err
+		}
+
+		if to.ID == 0 {
+			return apierror.Field("roomID", fmt.Sprintf("room %d does not exist", request.RoomID))
+		}
+
+		checkedIn := roomBooking.Status == StayStatusCheckedIn
+		today := dateOnly(s.Now())
+		first, last := roomBooking.firstNight(), roomBooking.lastNight()
+
+		date := today
+		if request.Date != nil {
+			date = dateOnly(*request.Date)
+		} else if date.Before(first) {
+			date = first
+		}
+
+		if date.Before(first) || date.After(last) {
+			return apierror.Field("date", "the move date must be one of the nights of the stay")
+		}
+
+		if checkedIn && date.After(today) {
+			return apierror.Field("date", "a checked in guest can't be moved on a later date")
+		}
+
+		if checkedIn && to.status() != RoomStatusInspected {
+			return apierror.Conflict(fmt.Sprintf("room %s is %s, a guest can only be moved into an inspected room", roomName(to), to.status()))
+		}
+
+		kept := uint(date.Sub(first).Hours() / 24)
+		moved := stayNights(date, roomBooking.NumberOfNights-kept)
+
+		booked, err := tx.Bookings().BookedNights(to.ID)
+		if err != nil {
+			return err
+		}
+
+		if err = checkClash(to, booked, moved); err != nil {
+			return err
+		}
+
+		blocked, err := tx.Maintenance().BlockedNights(to.ID, 0)
+		if err != nil {
+			return err
+		}
+
+		if err = checkBlocked(to, blocked, moved); err != nil {
+			return err
+		}
+
+		nights, err := priceNights(tx.RatePlans(), to, moved, request.Amount, false)
+		if err != nil {
+			return err
+		}
+
+		if kept == 0 {
+			move.To, err = s.moveWholeStay(tx, roomBooking, to, nights)
+		} else {
+			move, err = s.splitStay(tx, roomBooking, from, to, kept, nights)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = updateBookingAmount(tx, roomBooking.BookingID); err != nil {
+			return err
+		}
+
+		if !checkedIn {
+			return nil
+		}
+
+		if _, err = moveRoom(tx, s.actor, to, RoomStatusOccupied); err != nil {
+			return err
+		}
+
+		return leaveRoom(tx, s.actor, roomBooking)
+	})
+
+	return move, err
+}
+
+// moveWholeStay move a room booking to room to from its first night, nights are its nights priced for the new room
+func (s *BookingService) moveWholeStay(tx Store, roomBooking RoomBookings, to Room, nights []*RoomBookingNight) (RoomBookings, error) {
+	if err := tx.Bookings().ReplaceNights(roomBooking.ID, nights); err != nil {
+		return roomBooking, err
+	}
+
+	updates := map[string]any{
+		"RoomID": to.ID,
+		"Amount": averageRate(nights),
+	}
+
+	if err := tx.Bookings().UpdateRoomBooking(roomBooking.ID, updates); err != nil {
+		return roomBooking, err
+	}
+
+	after, err := tx.Bookings().FindRoomBooking(roomBooking.ID)
+	if err != nil {
+		return after, err
+	}
+
+	after.Nights = nights
+	return after, s.record(tx, audit.ActionMove, audit.EntityRoomBooking, roomBooking.ID, roomBooking, after)
+}
+
+// splitStay end a room booking after its first kept nights and book the rest, priced as nights, in room to
+func (s *BookingService) splitStay(tx Store, roomBooking RoomBookings, from, to Room, kept uint, nights []*RoomBookingNight) (RoomMove, error) {
+	var move RoomMove
+	var err error
+
+	// room bookings made before per night pricing are priced at their flat rate first
+	booked := roomBooking.Nights
+	if len(booked) < int(kept) {
+		rate := from.Price
+		if roomBooking.Amount != nil {
+			rate = *roomBooking.Amount
+		}
+
+		if booked, err = priceNights(tx.RatePlans(), from, stayNights(dateOnly(roomBooking.StartDate), roomBooking.NumberOfNights), &rate, false); err != nil {
+			return move, err
+		}
+	}
+
+	keptNights := make([]*RoomBookingNight, 0, kept)
+	for _, night := range booked[:kept] {
+		keptNights = append(keptNights, &RoomBookingNight{Date: night.Date, Rate: night.Rate, RatePlanID: night.RatePlanID})
+	}
+
+	if err = tx.Bookings().ReplaceNights(roomBooking.ID, keptNights); err != nil {
+		return move, err
+	}
+
+	end := roomBooking.StartDate.AddDate(0, 0, int(kept))
+
+	rest := &RoomBookings{
+		NumberOfNights: roomBooking.NumberOfNights - kept,
+		Status:         roomBooking.Status,
+		StartDate:      end,
+		EndDate:        roomBooking.EndDate,
+		BookingID:      roomBooking.BookingID,
+		RoomID:         to.ID,
+		MovedFromID:    &roomBooking.ID,
+		Nights:         nights,
+	}
+
+	amount := averageRate(nights)
+	rest.Amount = &amount
+
+	// a guest that is in arrives in the new room now and leaves the old one
+	updates := map[string]any{
+		"NumberOfNights": kept,
+		"EndDate":        end,
+		"Amount":         averageRate(keptNights),
+	}
+
+	if rest.Status == StayStatusCheckedIn {
+		now := s.Now()
+		rest.CheckedInAt = &now
+		updates["Status"] = StayStatusCheckedOut
+		updates["CheckedOutAt"] = now
+	}
+
+	if err = tx.Bookings().CreateRoomBooking(rest); err != nil {
+		return move, err
+	}
+
+	if err = tx.Bookings().UpdateRoomBooking(roomBooking.ID, updates); err != nil {
+		return move, err
+	}
+
+	after, err := tx.Bookings().FindRoomBooking(roomBooking.ID)
+	if err != nil {
+		return move, err
+	}
+
+	after.Nights = keptNights
+
+	if err = s.record(tx, audit.ActionMove, audit.EntityRoomBooking, roomBooking.ID, roomBooking, after); err != nil {
+		return move, err
+	}
+
+	if err = s.record(tx, audit.ActionCreate, audit.EntityRoomBooking, rest.ID, nil, rest); err != nil {
+		return move, err
+	}
+
+	move.From = &after
+	move.To = *rest
+	return move, nil
+}
@@ -0,0 +1,99 @@
+package room
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+// roomAmountOwed what the guest has to pay for the rooms, a cancelled booking only owes its cancellation fee
+func (b *Booking) roomAmountOwed() float64 {
+	switch {
+	case b.IsComplementary:
+		return 0
+	case b.Status == BookingStatusCancelled:
+		if b.CancellationFee == nil {
+			return 0
+		}
+		return *b.CancellationFee
+	case b.Amount == nil:
+		return 0
+	default:
+		return *b.Amount
+	}
+}
+
+// AmountOwed what the guest has to pay for the rooms and the folio charges, charges are owed on complementary bookings too
+func (b *Booking) AmountOwed() float64 {
+	return b.roomAmountOwed() + b.ChargesTotal
+}
+
+// computeBalance work out ChargesTotal, AmountPaid and Balance from the loaded Charges and Payments,
+// a negative balance is owed to the guest
+func (b *Booking) computeBalance() {
+	b.ChargesTotal = 0
+	for _, charge := range b.Charges {
+		b.ChargesTotal += charge.Amount
+	}
+
+	b.AmountPaid = 0
+	for _, payment := range b.Payments {
+		b.AmountPaid += payment.Amount
+	}
+
+	b.Balance = b.AmountOwed() - b.AmountPaid
+}
+
+// AddPayment record a payment against a booking {body: [amount, method, reference, receptionist, paidAt]}
+func (h *Handler) AddPayment(c fiber.Ctx) error {
+	bookingId, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	payment := new(Payment)
+
+	if err = c.Bind().JSON(payment); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	booking, err := h.bookings.By(rbac.UserID(c)).AddPayment(uint(bookingId), payment)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(booking)
+}
+
+// GetPayments get the payment ledger of a booking
+func (h *Handler) GetPayments(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("booking")
+	}
+
+	booking, err := h.store.Bookings().Find(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if booking.ID == 0 {
+		return apierror.NotFound("booking")
+	}
+
+	if err = h.store.Bookings().LoadFolio(&booking); err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"payments":   booking.Payments,
+		"amountOwed": booking.AmountOwed(),
+		"amountPaid": booking.AmountPaid,
+		"balance":    booking.Balance,
+	})
+}
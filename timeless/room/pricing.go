@@ -0,0 +1,115 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+)
+
+// WeekendNights nights that get a rate plan's weekend uplift
+var WeekendNights = []time.Weekday{time.Friday, time.Saturday}
+
+// appliesOn check if the plan covers the night, a missing start or end date leaves that side open
+func (p RatePlan) appliesOn(night time.Time) bool {
+	if p.StartDate != nil && night.Before(dateOnly(*p.StartDate)) {
+		return false
+	}
+
+	if p.EndDate != nil && night.After(dateOnly(*p.EndDate)) {
+		return false
+	}
+
+	return true
+}
+
+// rateOn get the plan's price for the night including the weekend uplift
+func (p RatePlan) rateOn(night time.Time) float64 {
+	if slices.Contains(WeekendNights, night.Weekday()) {
+		return p.Price * (1 + p.WeekendUplift/100)
+	}
+
+	return p.Price
+}
+
+// priceNights price each night of a stay in room r. A non nil flatRate is charged for every night
+// instead of the rate plans, nights with no plan are charged at the room price
+func priceNights(ratePlans RatePlanRepository, r Room, nights []time.Time, flatRate *float64, checkMinimumStay bool) ([]*RoomBookingNight, error) {
+	pricedNights := make([]*RoomBookingNight, 0, len(nights))
+
+	if flatRate != nil {
+		for _, night := range nights {
+			pricedNights = append(pricedNights, &RoomBookingNight{Date: night, Rate: *flatRate})
+		}
+
+		return pricedNights, nil
+	}
+
+	plans, err := ratePlans.ForRoom(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, night := range nights {
+		pricedNight := &RoomBookingNight{Date: night, Rate: r.Price}
+
+		for _, plan := range plans {
+			if !plan.appliesOn(night) {
+				continue
+			}
+
+			if checkMinimumStay && uint(len(nights)) < plan.MinimumStay {
+				return nil, apierror.Field("numberOfNights", fmt.Sprintf("rate plan %s requires a minimum stay of %d nights", *plan.Name, plan.MinimumStay))
+			}
+
+			planID := plan.ID
+			pricedNight.Rate = plan.rateOn(night)
+			pricedNight.RatePlanID = &planID
+			break
+		}
+
+		pricedNights = append(pricedNights, pricedNight)
+	}
+
+	return pricedNights, nil
+}
+
+// nightsTotal sum the rates of priced nights
+func nightsTotal(nights []*RoomBookingNight) float64 {
+	total := 0.0
+	for _, night := range nights {
+		total += night.Rate
+	}
+
+	return total
+}
+
+// Total the amount charged for a room booking, room bookings made before
+// per night pricing only have the nightly Amount
+func (rb *RoomBookings) Total() float64 {
+	if len(rb.Nights) > 0 {
+		return nightsTotal(rb.Nights)
+	}
+
+	if rb.Amount == nil {
+		return 0
+	}
+
+	return *rb.Amount * float64(rb.NumberOfNights)
+}
+
+// averageRate the nightly Amount shown on a room booking once its nights are priced separately
+func averageRate(nights []*RoomBookingNight) float64 {
+	if len(nights) == 0 {
+		return 0
+	}
+
+	return nightsTotal(nights) / float64(len(nights))
+}
+
+// dateOnly truncate t to midnight UTC of its day, the form booked nights are compared in
+func dateOnly(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
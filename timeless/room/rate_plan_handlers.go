@@ -0,0 +1,154 @@
+package room
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/rbac"
+	"github.com/hidenkeys/timeless/validation"
+)
+
+func (h *Handler) CreateRatePlan(c fiber.Ctx) error {
+	newRatePlan := new(RatePlan)
+
+	if err := c.Bind().JSON(newRatePlan); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	err := h.store.Transaction(func(tx Store) error {
+		if err := tx.RatePlans().Create(newRatePlan); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionCreate, audit.EntityRatePlan, newRatePlan.ID, nil, newRatePlan)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusCreated).JSON(newRatePlan)
+}
+
+// GetAllRatePlans params {roomId, category}
+func (h *Handler) GetAllRatePlans(c fiber.Ctx) error {
+	ratePlans, err := h.store.RatePlans().List(c.Query("roomId"), c.Query("category"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(ratePlans)
+}
+
+func (h *Handler) GetRatePlanById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("rate plan")
+	}
+
+	ratePlan, err := h.store.RatePlans().Find(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if ratePlan.ID == 0 {
+		return apierror.NotFound("rate plan")
+	}
+
+	return c.Status(http.StatusOK).JSON(ratePlan)
+}
+
+// ratePlanFields the fields of a rate plan UpdateRatePlan can change
+var ratePlanFields = []string{"name", "roomID", "category", "startDate", "endDate", "price", "weekendUplift", "minimumStay", "priority"}
+
+func (h *Handler) UpdateRatePlan(c fiber.Ctx) error {
+	newRatePlanInfo := &validation.Partial{Value: new(RatePlan)}
+	ratePlanID, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("rate plan")
+	}
+
+	if err = c.Bind().JSON(newRatePlanInfo); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	fields, err := newRatePlanInfo.Fields(ratePlanFields...)
+	if err != nil {
+		return err
+	}
+
+	var ratePlan RatePlan
+	err = h.store.Transaction(func(tx Store) error {
+		before, err := tx.RatePlans().Find(uint(ratePlanID))
+		if err != nil {
+			return err
+		}
+
+		if before.ID == 0 {
+			return apierror.NotFound("rate plan")
+		}
+
+		// only the dates sent were validated, check them against the stored ones they are merged with
+		start, end := before.StartDate, before.EndDate
+		if date, sent := fields["StartDate"]; sent {
+			start = date.(*time.Time)
+		}
+		if date, sent := fields["EndDate"]; sent {
+			end = date.(*time.Time)
+		}
+
+		if start != nil && end != nil && end.Before(*start) {
+			return apierror.Field("endDate", "endDate must not be before startDate")
+		}
+
+		if err = tx.RatePlans().Update(before.ID, fields); err != nil {
+			return err
+		}
+
+		if ratePlan, err = tx.RatePlans().Find(before.ID); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionUpdate, audit.EntityRatePlan, before.ID, before, ratePlan)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(ratePlan)
+}
+
+func (h *Handler) DeleteRatePlan(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("rate plan")
+	}
+
+	err = h.store.Transaction(func(tx Store) error {
+		ratePlan, err := tx.RatePlans().Find(uint(id))
+		if err != nil {
+			return err
+		}
+
+		if ratePlan.ID == 0 {
+			return apierror.NotFound("rate plan")
+		}
+
+		if err = tx.RatePlans().Delete(ratePlan.ID); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionDelete, audit.EntityRatePlan, ratePlan.ID, ratePlan, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
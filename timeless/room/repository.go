@@ -0,0 +1,715 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Store the repositories the room and booking code works through. Transaction runs fn
+// with a Store bound to one database transaction, it commits when fn returns nil
+type Store interface {
+	Rooms() RoomRepository
+	Bookings() BookingRepository
+	RatePlans() RatePlanRepository
+	Tasks() TaskRepository
+	Maintenance() MaintenanceRepository
+	// Audit the audit log, changes made in a transaction are recorded through the transaction's Store
+	Audit() audit.Log
+	Transaction(fn func(Store) error) error
+}
+
+// RoomRepository rooms, a room that doesn't exist comes back with ID 0
+type RoomRepository interface {
+	Find(id uint) (Room, error)
+	// Lock find a room and hold a lock on it until the transaction ends
+	Lock(id uint) (Room, error)
+	FindMany(ids []uint) ([]Room, error)
+	// Search one page of the rooms matching every field of the filter
+	Search(filter RoomFilter, page pagination.Params) ([]Room, int64, error)
+	Categories() ([]string, error)
+	// Available rooms with no booked or blocked night from start up to (not including) end, maxPrice 0 means no limit
+	Available(start, end time.Time, category string, maxPrice float64) ([]Room, error)
+	Create(r *Room) error
+	Update(id uint, fields map[string]any) error
+	SetStatus(id uint, status string) error
+}
+
+// RoomFilter the room search query parameters, an empty field or a 0 price doesn't filter
+type RoomFilter struct {
+	Name     string
+	Category string
+	Status   string
+	MinPrice float64
+	MaxPrice float64
+}
+
+// RoomSorts what the room search can be sorted by
+var RoomSorts = pagination.Sorts{
+	"id":        "id",
+	"name":      "name",
+	"category":  "category",
+	"status":    "status",
+	"price":     "price",
+	"createdAt": "created_at",
+}
+
+// BookingSorts what the booking lists can be sorted by
+var BookingSorts = pagination.Sorts{
+	"id":        "id",
+	"createdAt": "created_at",
+	"amount":    "amount",
+	"status":    "status",
+}
+
+// BookingFilter the GetAllBookings query parameters
+type BookingFilter struct {
+	Start      string
+	End        string
+	EmployeeID string
+	Status     string
+}
+
+// BookingSummary the figures GetBookingSummary reports for a date range
+type BookingSummary struct {
+	SumAmount        float64
+	NumberOfBookings float64
+	CheckIn          uint
+	CheckOut         uint
+	AvailableRooms   uint
+	ByPaymentMethod  map[string]float64
+}
+
+// BookingRepository bookings, their room bookings and folios. A booking or room booking that
+// doesn't exist comes back with ID 0
+type BookingRepository interface {
+	// List one page of the bookings matching filter and how many match in all
+	List(filter BookingFilter, page pagination.Params) ([]Booking, int64, error)
+	ForCustomer(customerID uint, page pagination.Params) ([]Booking, int64, error)
+	Find(id uint) (Booking, error)
+	// FindWithRooms find a booking with its room bookings, their nights in date order
+	FindWithRooms(id uint) (Booking, error)
+	// FindFolio find a booking with its priced room bookings, charges and payments and compute its balance
+	FindFolio(id uint) (Booking, error)
+	// LoadFolio load the charges and payments of a booking and compute its balance
+	LoadFolio(booking *Booking) error
+	Create(booking *Booking) error
+	// Update change the columns of a booking, fields is a map or a struct as gorm's Updates takes them
+	Update(id uint, fields any) error
+	Delete(id uint) error
+	Summary(start, end string, now time.Time) (BookingSummary, error)
+
+	FindRoomBooking(id uint) (RoomBookings, error)
+	// LockRoomBooking find a room booking and hold a lock on it until the transaction ends
+	LockRoomBooking(id uint) (RoomBookings, error)
+	FindRoomBookingOf(bookingID, roomBookingID uint) (RoomBookings, error)
+	// RoomBookingsOf the room bookings of a booking with their nights
+	RoomBookingsOf(bookingID uint) ([]*RoomBookings, error)
+	// CreateRoomBooking add a room booking to an existing booking, with its nights
+	CreateRoomBooking(roomBooking *RoomBookings) error
+	UpdateRoomBooking(id uint, fields any) error
+	Nights(roomBookingID uint) ([]*RoomBookingNight, error)
+	AddNights(nights []*RoomBookingNight) error
+	ReplaceNights(roomBookingID uint, nights []*RoomBookingNight) error
+	// BookedNights every night the room is booked, in order
+	BookedNights(roomID uint) ([]time.Time, error)
+
+	AddPayment(payment *Payment) error
+	AddCharge(charge *Charge) error
+	// FindCharge find a charge posted to a booking
+	FindCharge(bookingID, chargeID uint) (Charge, error)
+	// VoidCharge delete a charge of a booking, false when there is no such charge
+	VoidCharge(bookingID, chargeID uint) (bool, error)
+}
+
+// RatePlanRepository rate plans, a plan that doesn't exist comes back with ID 0
+type RatePlanRepository interface {
+	// ForRoom the rate plans that can apply to a room, the one to use first
+	ForRoom(r Room) ([]RatePlan, error)
+	List(roomID, category string) ([]RatePlan, error)
+	Find(id uint) (RatePlan, error)
+	Create(plan *RatePlan) error
+	Update(id uint, fields map[string]any) error
+	Delete(id uint) error
+}
+
+// TaskRepository housekeeping tasks, a task that doesn't exist comes back with ID 0
+type TaskRepository interface {
+	// List one page of the tasks matching filter and how many match in all
+	List(filter TaskFilter, page pagination.Params) ([]HousekeepingTask, int64, error)
+	Find(id uint) (HousekeepingTask, error)
+	// Lock find a task and hold a lock on it until the transaction ends
+	Lock(id uint) (HousekeepingTask, error)
+	// Unfinished the open or claimed tasks of a room
+	Unfinished(roomID uint) ([]HousekeepingTask, error)
+	Create(task *HousekeepingTask) error
+	Update(id uint, fields map[string]any) error
+}
+
+// TaskFilter the task list query parameters, an empty field doesn't filter
+type TaskFilter struct {
+	Status     string
+	Type       string
+	RoomID     uint
+	AssignedTo uint
+}
+
+// TaskSorts what the task list can be sorted by
+var TaskSorts = pagination.Sorts{
+	"id":        "id",
+	"createdAt": "created_at",
+	"status":    "status",
+	"roomID":    "room_id",
+}
+
+// MaintenanceRepository the maintenance blocks of rooms, a block that doesn't exist comes back with ID 0
+type MaintenanceRepository interface {
+	// ForRoom every block of a room, closed ones included, by start date
+	ForRoom(roomID uint) ([]MaintenanceBlock, error)
+	// Find find a block of a room
+	Find(roomID, id uint) (MaintenanceBlock, error)
+	// BlockedNights every night the open blocks of a room cover bar the block except, in order
+	BlockedNights(roomID, except uint) ([]time.Time, error)
+	Create(block *MaintenanceBlock) error
+	Update(id uint, fields map[string]any) error
+}
+
+// NewStore a Store backed by db
+func NewStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+func (s *gormStore) Rooms() RoomRepository              { return roomRepository{s.db} }
+func (s *gormStore) Bookings() BookingRepository        { return bookingRepository{s.db} }
+func (s *gormStore) RatePlans() RatePlanRepository      { return ratePlanRepository{s.db} }
+func (s *gormStore) Tasks() TaskRepository              { return taskRepository{s.db} }
+func (s *gormStore) Maintenance() MaintenanceRepository { return maintenanceRepository{s.db} }
+func (s *gormStore) Audit() audit.Log                   { return audit.NewLog(s.db) }
+
+func (s *gormStore) Transaction(fn func(Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+type roomRepository struct {
+	db *gorm.DB
+}
+
+func (r roomRepository) Find(id uint) (Room, error) {
+	var room Room
+	return room, r.db.Where("id = ?", id).Find(&room).Error
+}
+
+// Lock sqlite has no row locks, the connection is opened with _txlock=immediate instead
+func (r roomRepository) Lock(id uint) (Room, error) {
+	var room Room
+	return room, r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&room).Error
+}
+
+func (r roomRepository) FindMany(ids []uint) ([]Room, error) {
+	var rooms []Room
+	return rooms, r.db.Where("id IN ?", ids).Find(&rooms).Error
+}
+
+func (r roomRepository) Search(filter RoomFilter, page pagination.Params) ([]Room, int64, error) {
+	query := r.db.Model(&Room{})
+
+	if filter.Name != "" {
+		query = query.Where("name = ?", filter.Name)
+	}
+
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.MinPrice > 0 {
+		query = query.Where("price >= ?", filter.MinPrice)
+	}
+
+	if filter.MaxPrice > 0 {
+		query = query.Where("price <= ?", filter.MaxPrice)
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var rooms []Room
+	if result := query.Scopes(page.Scope).Find(&rooms); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return rooms, total, nil
+}
+
+func (r roomRepository) Categories() ([]string, error) {
+	var categories []string
+	return categories, r.db.Raw("SELECT DISTINCT category FROM rooms").Find(&categories).Error
+}
+
+func (r roomRepository) Available(start, end time.Time, category string, maxPrice float64) ([]Room, error) {
+	var generateSQL strings.Builder
+	generateSQL.WriteString(getAvailableRoomsQuery)
+	params := []any{end.Format(time.DateOnly), start.Format(time.DateOnly), end.Format(time.DateOnly), start.Format(time.DateOnly)}
+
+	if category != "" {
+		generateSQL.WriteString("AND category = ? ")
+		params = append(params, category)
+	}
+
+	if maxPrice > 0 {
+		generateSQL.WriteString("AND price <= ? ")
+		params = append(params, maxPrice)
+	}
+
+	generateSQL.WriteString("ORDER BY price, name")
+
+	var rooms []Room
+	return rooms, r.db.Raw(generateSQL.String(), params...).Find(&rooms).Error
+}
+
+func (r roomRepository) Create(room *Room) error {
+	return r.db.Create(room).Error
+}
+
+func (r roomRepository) Update(id uint, fields map[string]any) error {
+	return r.db.Model(&Room{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+func (r roomRepository) SetStatus(id uint, status string) error {
+	return r.db.Model(Room{}).Where("id = ?", id).Update("status", status).Error
+}
+
+type bookingRepository struct {
+	db *gorm.DB
+}
+
+func (r bookingRepository) List(filter BookingFilter, page pagination.Params) ([]Booking, int64, error) {
+	query := r.db.Model(&Booking{})
+
+	if filter.Start != "" {
+		query = query.Where("created_at >= ?", filter.Start)
+	}
+
+	if filter.End != "" {
+		query = query.Where("date(created_at) <= ?", filter.End)
+	}
+
+	if filter.EmployeeID != "" {
+		query = query.Where("receptionist = ?", filter.EmployeeID)
+	}
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	} else {
+		query = query.Where("status != ?", BookingStatusCancelled)
+	}
+
+	return r.page(query, page)
+}
+
+func (r bookingRepository) ForCustomer(customerID uint, page pagination.Params) ([]Booking, int64, error) {
+	return r.page(r.db.Model(&Booking{}).Where("customer_id = ?", customerID), page)
+}
+
+// page count the bookings query matches and load one page of them with their room bookings and folio
+func (r bookingRepository) page(query *gorm.DB, page pagination.Params) ([]Booking, int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var bookings []Booking
+	if result := query.Scopes(page.Scope).Preload("RoomBookings.Nights").Preload("Charges").Preload("Payments").Find(&bookings); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	for i := range bookings {
+		bookings[i].computeBalance()
+	}
+
+	return bookings, total, nil
+}
+
+func (r bookingRepository) Find(id uint) (Booking, error) {
+	var booking Booking
+	return booking, r.db.Where("id = ?", id).Find(&booking).Error
+}
+
+func (r bookingRepository) FindWithRooms(id uint) (Booking, error) {
+	var booking Booking
+
+	result := r.db.Preload("RoomBookings.Nights", func(db *gorm.DB) *gorm.DB {
+		return db.Order("date")
+	}).Where("id = ?", id).Find(&booking)
+
+	return booking, result.Error
+}
+
+func (r bookingRepository) FindFolio(id uint) (Booking, error) {
+	booking, err := r.FindWithRooms(id)
+	if err != nil || booking.ID == 0 {
+		return booking, err
+	}
+
+	return booking, r.LoadFolio(&booking)
+}
+
+func (r bookingRepository) LoadFolio(booking *Booking) error {
+	if result := r.db.Where("booking_id = ?", booking.ID).Order("created_at").Find(&booking.Charges); result.Error != nil {
+		return result.Error
+	}
+
+	if result := r.db.Where("booking_id = ?", booking.ID).Order("paid_at").Find(&booking.Payments); result.Error != nil {
+		return result.Error
+	}
+
+	booking.computeBalance()
+	return nil
+}
+
+func (r bookingRepository) Create(booking *Booking) error {
+	return r.db.Create(booking).Error
+}
+
+func (r bookingRepository) Update(id uint, fields any) error {
+	return r.db.Model(&Booking{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+func (r bookingRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&Booking{}).Error
+}
+
+func (r bookingRepository) Summary(start, end string, now time.Time) (BookingSummary, error) {
+	summary := BookingSummary{ByPaymentMethod: make(map[string]float64)}
+
+	var params []any
+	var whereClause strings.Builder
+
+	// check ins and check outs are counted by the day they happen, not by when the booking was made
+	var checkInParams, checkOutParams []any
+	var checkInClause, checkOutClause strings.Builder
+
+	whereClause.WriteString("deleted_at is null AND status != ? ")
+	params = append(params, BookingStatusCancelled)
+
+	if start != "" {
+		whereClause.WriteString("AND created_at >= ? ")
+		params = append(params, start)
+
+		checkInClause.WriteString("AND date(start_date) >= ? ")
+		checkInParams = append(checkInParams, start)
+		checkOutClause.WriteString("AND date(end_date) >= ? ")
+		checkOutParams = append(checkOutParams, start)
+	}
+
+	if end != "" {
+		whereClause.WriteString("AND date(created_at) <= ? ")
+		params = append(params, end)
+
+		checkInClause.WriteString("AND date(start_date) <= ? ")
+		checkInParams = append(checkInParams, end)
+		checkOutClause.WriteString("AND date(end_date) <= ? ")
+		checkOutParams = append(checkOutParams, end)
+	}
+
+	sqlString := fmt.Sprintf(getSummaryQuery, whereClause.String(), checkInClause.String(), checkOutClause.String())
+	summaryParams := append(append(append(append([]any{}, params...), checkInParams...), checkOutParams...), now, now)
+
+	row := r.db.Raw(sqlString, summaryParams...).Row()
+	if err := row.Scan(&summary.SumAmount, &summary.NumberOfBookings, &summary.CheckIn, &summary.CheckOut, &summary.AvailableRooms); err != nil {
+		return summary, err
+	}
+
+	rows, err := r.db.Raw(fmt.Sprintf(getSummaryByPaymentMethodQuery, whereClause.String()), params...).Rows()
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var method string
+		var amount float64
+		if err = rows.Scan(&method, &amount); err != nil {
+			return summary, err
+		}
+
+		summary.ByPaymentMethod[method] = amount
+	}
+
+	return summary, rows.Err()
+}
+
+func (r bookingRepository) FindRoomBooking(id uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+	return roomBooking, r.db.Where("id = ?", id).Find(&roomBooking).Error
+}
+
+func (r bookingRepository) LockRoomBooking(id uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+	return roomBooking, r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&roomBooking).Error
+}
+
+func (r bookingRepository) FindRoomBookingOf(bookingID, roomBookingID uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+	return roomBooking, r.db.Where("booking_id = ? AND id = ?", bookingID, roomBookingID).Find(&roomBooking).Error
+}
+
+func (r bookingRepository) RoomBookingsOf(bookingID uint) ([]*RoomBookings, error) {
+	var roomBookings []*RoomBookings
+	return roomBookings, r.db.Preload("Nights").Where("booking_id = ?", bookingID).Find(&roomBookings).Error
+}
+
+func (r bookingRepository) CreateRoomBooking(roomBooking *RoomBookings) error {
+	return r.db.Create(roomBooking).Error
+}
+
+func (r bookingRepository) UpdateRoomBooking(id uint, fields any) error {
+	return r.db.Model(&RoomBookings{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+func (r bookingRepository) Nights(roomBookingID uint) ([]*RoomBookingNight, error) {
+	var nights []*RoomBookingNight
+	return nights, r.db.Where("room_booking_id = ?", roomBookingID).Order("date").Find(&nights).Error
+}
+
+func (r bookingRepository) AddNights(nights []*RoomBookingNight) error {
+	if len(nights) == 0 {
+		return nil
+	}
+
+	return r.db.Create(nights).Error
+}
+
+func (r bookingRepository) ReplaceNights(roomBookingID uint, nights []*RoomBookingNight) error {
+	if result := r.db.Unscoped().Where("room_booking_id = ?", roomBookingID).Delete(&RoomBookingNight{}); result.Error != nil {
+		return result.Error
+	}
+
+	for _, night := range nights {
+		night.RoomBookingID = roomBookingID
+	}
+
+	return r.AddNights(nights)
+}
+
+// liveRoomBookings room bookings still holding their room: reserved or checked in and from a booking that wasn't cancelled
+func liveRoomBookings(db *gorm.DB) *gorm.DB {
+	return db.Model(&RoomBookings{}).
+		Joins("JOIN bookings b ON b.id = room_bookings.booking_id").
+		Where("room_bookings.status IN ? AND b.deleted_at is null AND b.status != ?", []string{StayStatusReserved, StayStatusCheckedIn}, BookingStatusCancelled)
+}
+
+func (r bookingRepository) BookedNights(roomID uint) ([]time.Time, error) {
+	var roomBookings []RoomBookings
+	if result := liveRoomBookings(r.db).Where("room_bookings.room_id = ?", roomID).Select("room_bookings.start_date, room_bookings.end_date").Find(&roomBookings); result.Error != nil {
+		return []time.Time{}, result.Error
+	}
+
+	booked := make(map[time.Time]bool)
+	for _, roomBooking := range roomBookings {
+		// the first night counts even when the dates are the same day
+		first, last := dateOnly(roomBooking.StartDate), dateOnly(roomBooking.EndDate)
+		for night := first; night.Equal(first) || night.Before(last); night = night.AddDate(0, 0, 1) {
+			booked[night] = true
+		}
+	}
+
+	bookedDates := make([]time.Time, 0, len(booked))
+	for night := range booked {
+		bookedDates = append(bookedDates, night)
+	}
+	slices.SortFunc(bookedDates, time.Time.Compare)
+
+	return bookedDates, nil
+}
+
+func (r bookingRepository) AddPayment(payment *Payment) error {
+	return r.db.Create(payment).Error
+}
+
+func (r bookingRepository) AddCharge(charge *Charge) error {
+	return r.db.Create(charge).Error
+}
+
+func (r bookingRepository) FindCharge(bookingID, chargeID uint) (Charge, error) {
+	var charge Charge
+	return charge, r.db.Where("id = ? AND booking_id = ?", chargeID, bookingID).Find(&charge).Error
+}
+
+func (r bookingRepository) VoidCharge(bookingID, chargeID uint) (bool, error) {
+	result := r.db.Where("id = ? AND booking_id = ?", chargeID, bookingID).Delete(&Charge{})
+	return result.RowsAffected > 0, result.Error
+}
+
+type ratePlanRepository struct {
+	db *gorm.DB
+}
+
+// ForRoom higher priority wins, then room plans beat category plans which beat hotel wide plans, then newer plans win
+func (r ratePlanRepository) ForRoom(room Room) ([]RatePlan, error) {
+	var plans []RatePlan
+
+	query := r.db.Where("room_id = ? or (room_id is null and category is null)", room.ID)
+	if room.Category != nil {
+		query = r.db.Where("room_id = ? or (room_id is null and (category = ? or category is null))", room.ID, *room.Category)
+	}
+
+	if result := query.Order("priority desc, room_id is null, category is null, id desc").Find(&plans); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return plans, nil
+}
+
+func (r ratePlanRepository) List(roomID, category string) ([]RatePlan, error) {
+	var ratePlans []RatePlan
+
+	query := r.db.Order("id")
+
+	if roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	return ratePlans, query.Find(&ratePlans).Error
+}
+
+func (r ratePlanRepository) Find(id uint) (RatePlan, error) {
+	var ratePlan RatePlan
+	return ratePlan, r.db.Where("id = ?", id).Find(&ratePlan).Error
+}
+
+func (r ratePlanRepository) Create(plan *RatePlan) error {
+	return r.db.Create(plan).Error
+}
+
+func (r ratePlanRepository) Update(id uint, fields map[string]any) error {
+	return r.db.Model(&RatePlan{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+func (r ratePlanRepository) Delete(id uint) error {
+	return r.db.Where("id = ?", id).Delete(&RatePlan{}).Error
+}
+
+type taskRepository struct {
+	db *gorm.DB
+}
+
+func (r taskRepository) List(filter TaskFilter, page pagination.Params) ([]HousekeepingTask, int64, error) {
+	query := r.db.Model(&HousekeepingTask{})
+
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+
+	if filter.RoomID != 0 {
+		query = query.Where("room_id = ?", filter.RoomID)
+	}
+
+	if filter.AssignedTo != 0 {
+		query = query.Where("assigned_to = ?", filter.AssignedTo)
+	}
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var tasks []HousekeepingTask
+	if result := query.Scopes(page.Scope).Find(&tasks); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return tasks, total, nil
+}
+
+func (r taskRepository) Find(id uint) (HousekeepingTask, error) {
+	var task HousekeepingTask
+	return task, r.db.Where("id = ?", id).Find(&task).Error
+}
+
+// Lock sqlite has no row locks, the connection is opened with _txlock=immediate instead
+func (r taskRepository) Lock(id uint) (HousekeepingTask, error) {
+	var task HousekeepingTask
+	return task, r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&task).Error
+}
+
+func (r taskRepository) Unfinished(roomID uint) ([]HousekeepingTask, error) {
+	var tasks []HousekeepingTask
+	return tasks, r.db.Where("room_id = ? AND status != ?", roomID, TaskStatusDone).Order("id").Find(&tasks).Error
+}
+
+func (r taskRepository) Create(task *HousekeepingTask) error {
+	return r.db.Create(task).Error
+}
+
+func (r taskRepository) Update(id uint, fields map[string]any) error {
+	return r.db.Model(&HousekeepingTask{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+type maintenanceRepository struct {
+	db *gorm.DB
+}
+
+func (r maintenanceRepository) ForRoom(roomID uint) ([]MaintenanceBlock, error) {
+	var blocks []MaintenanceBlock
+	return blocks, r.db.Where("room_id = ?", roomID).Order("start_date, id").Find(&blocks).Error
+}
+
+func (r maintenanceRepository) Find(roomID, id uint) (MaintenanceBlock, error) {
+	var block MaintenanceBlock
+	return block, r.db.Where("room_id = ? AND id = ?", roomID, id).Find(&block).Error
+}
+
+func (r maintenanceRepository) BlockedNights(roomID, except uint) ([]time.Time, error) {
+	var blocks []MaintenanceBlock
+	if result := r.db.Where("room_id = ? AND closed_at IS NULL AND id != ?", roomID, except).Find(&blocks); result.Error != nil {
+		return nil, result.Error
+	}
+
+	var blocked []time.Time
+	for _, block := range blocks {
+		blocked = append(blocked, block.nights()...)
+	}
+	slices.SortFunc(blocked, time.Time.Compare)
+
+	return slices.CompactFunc(blocked, time.Time.Equal), nil
+}
+
+func (r maintenanceRepository) Create(block *MaintenanceBlock) error {
+	return r.db.Create(block).Error
+}
+
+func (r maintenanceRepository) Update(id uint, fields map[string]any) error {
+	return r.db.Model(&MaintenanceBlock{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
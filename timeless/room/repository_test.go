@@ -0,0 +1,208 @@
+package room
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"gorm.io/gorm"
+)
+
+// TestMain stop the database servers ForEachDialect started
+func TestMain(m *testing.M) {
+	storagetest.Main(m)
+}
+
+// day noon on a day of march 2026, when stays start and end
+func day(d int) time.Time {
+	return time.Date(2026, time.March, d, 12, 0, 0, 0, time.UTC)
+}
+
+// newRoom a room named name in category at price
+func newRoom(name, category string, price float64) *Room {
+	status := RoomStatusInspected
+	return &Room{Name: &name, Category: &category, Price: price, Status: &status}
+}
+
+// newStay a booking of one room from the night of first for nights at rate a night, the stay has status
+func newStay(roomID uint, first, nights int, rate float64, status string) *RoomBookings {
+	stay := &RoomBookings{
+		RoomID:         roomID,
+		NumberOfNights: uint(nights),
+		StartDate:      day(first),
+		EndDate:        day(first + nights),
+		Status:         status,
+		Amount:         &rate,
+	}
+
+	for _, night := range stayNights(dateOnly(stay.StartDate), stay.NumberOfNights) {
+		stay.Nights = append(stay.Nights, &RoomBookingNight{Date: night, Rate: rate})
+	}
+
+	return stay
+}
+
+// newBooking a booking made on the 20th of february by receptionist 1, paid with method. tests against postgres and
+// mysql create the receptionist with storagetest.NewUser(1) first
+func newBooking(method string, stays ...*RoomBookings) *Booking {
+	amount := 0.0
+	for _, stay := range stays {
+		amount += stay.Total()
+	}
+
+	booking := &Booking{Receptionist: 1, PaymentMethod: method, Amount: &amount, Status: BookingStatusActive, RoomBookings: stays}
+	booking.CreatedAt = time.Date(2026, time.February, 20, 9, 0, 0, 0, time.UTC)
+	return booking
+}
+
+func TestBookingSummary(t *testing.T) {
+	storagetest.ForEachDialect(t, func(t *testing.T, db *gorm.DB) {
+		storagetest.Create(t, db,
+			storagetest.NewUser(1),
+			newRoom("101", "standard", 100),
+			newRoom("102", "standard", 100),
+			newRoom("103", "deluxe", 200),
+			newBooking("cash", newStay(1, 1, 2, 50, StayStatusCheckedIn)),
+			newBooking("Cash", newStay(2, 2, 2, 25, StayStatusCheckedOut)),
+		)
+
+		cancelled := newBooking("transfer", newStay(3, 1, 1, 70, StayStatusCancelled))
+		cancelled.Status = BookingStatusCancelled
+		storagetest.Create(t, db, cancelled)
+
+		summary, err := NewStore(db).Bookings().Summary("2026-02-01", "2026-03-31", day(2).Add(3*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if summary.SumAmount != 150 || summary.NumberOfBookings != 2 {
+			t.Errorf("%v bookings for %v, want the 2 that weren't cancelled for 150", summary.NumberOfBookings, summary.SumAmount)
+		}
+
+		if summary.CheckIn != 2 || summary.CheckOut != 1 {
+			t.Errorf("%d check ins and %d check outs, want 2 and 1", summary.CheckIn, summary.CheckOut)
+		}
+
+		if summary.AvailableRooms != 2 {
+			t.Errorf("%d rooms available, want 2", summary.AvailableRooms)
+		}
+
+		if len(summary.ByPaymentMethod) != 1 || summary.ByPaymentMethod["cash"] != 150 {
+			t.Errorf("by payment method %v, want cash 150 whatever its case", summary.ByPaymentMethod)
+		}
+	})
+}
+
+func TestAvailableRooms(t *testing.T) {
+	storagetest.ForEachDialect(t, func(t *testing.T, db *gorm.DB) {
+		storagetest.Create(t, db,
+			storagetest.NewUser(1),
+			newRoom("101", "standard", 100),
+			newRoom("102", "standard", 120),
+			newRoom("103", "deluxe", 200),
+			newRoom("104", "standard", 90),
+			newBooking("cash", newStay(1, 3, 2, 100, StayStatusReserved)),
+			newBooking("cash", newStay(2, 1, 2, 100, StayStatusCheckedOut)),
+			&MaintenanceBlock{RoomID: 4, StartDate: dateOnly(day(4)), EndDate: dateOnly(day(4)), Reason: "leak"},
+		)
+
+		tests := []struct {
+			name       string
+			start, end time.Time
+			category   string
+			maxPrice   float64
+			want       []string
+		}{
+			{"a booked night", day(4), day(5), "", 0, []string{"102", "103"}},
+			{"the day the guest leaves", day(5), day(6), "", 0, []string{"104", "101", "102", "103"}},
+			{"the night before a block", day(3), day(4), "standard", 0, []string{"104", "102"}},
+			{"rooms up to a price", day(1), day(2), "", 100, []string{"104", "101"}},
+		}
+
+		rooms := NewStore(db).Rooms()
+		for _, test := range tests {
+			available, err := rooms.Available(dateOnly(test.start), dateOnly(test.end), test.category, test.maxPrice)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, 0, len(available))
+			for _, r := range available {
+				names = append(names, *r.Name)
+			}
+
+			if !slices.Equal(names, test.want) {
+				t.Errorf("%s: available %v, want %v", test.name, names, test.want)
+			}
+		}
+	})
+}
+
+// TestLock every dialect takes the lock of a room in a transaction, TestLockHoldsRoom checks it holds
+func TestLock(t *testing.T) {
+	storagetest.ForEachDialect(t, func(t *testing.T, db *gorm.DB) {
+		storagetest.Create(t, db, newRoom("101", "standard", 100))
+
+		err := NewStore(db).Transaction(func(tx Store) error {
+			r, err := tx.Rooms().Lock(1)
+			if err == nil && roomName(r) != "101" {
+				t.Errorf("locked room %q, want 101", roomName(r))
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestLockHoldsRoom a room locked in one transaction can't be locked in another until the first ends
+func TestLockHoldsRoom(t *testing.T) {
+	storagetest.ForEachLockingDialect(t, func(t *testing.T, db *gorm.DB) {
+		storagetest.Create(t, db, newRoom("101", "standard", 100))
+		store := NewStore(db)
+
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		first := make(chan error, 1)
+
+		go func() {
+			first <- store.Transaction(func(tx Store) error {
+				if _, err := tx.Rooms().Lock(1); err != nil {
+					return err
+				}
+
+				close(locked)
+				<-release
+				return nil
+			})
+		}()
+
+		<-locked
+
+		second := make(chan error, 1)
+		go func() {
+			second <- store.Transaction(func(tx Store) error {
+				_, err := tx.Rooms().Lock(1)
+				return err
+			})
+		}()
+
+		select {
+		case err := <-second:
+			t.Fatalf("second lock taken while the first transaction holds the room (err %v)", err)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		close(release)
+
+		if err := <-first; err != nil {
+			t.Fatal(err)
+		}
+
+		if err := <-second; err != nil {
+			t.Fatalf("second lock after the first transaction ended: %v", err)
+		}
+	})
+}
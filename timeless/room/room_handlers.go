@@ -3,98 +3,218 @@ package room
 import (
 	"fmt"
 	"github.com/gofiber/fiber/v3"
-	"github.com/hidenkeys/timeless/storage"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/rbac"
+	"github.com/hidenkeys/timeless/validation"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
-func Create(c fiber.Ctx) error {
+func (h *Handler) Create(c fiber.Ctx) error {
 	newRoom := new(Room)
 
 	if err := c.Bind().JSON(newRoom); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
 	}
 
-	//status := "available"
-	//newRoom.Status = &status
+	if newRoom.Status != nil {
+		return apierror.Field("status", "a new room is vacant_clean, its status is changed through PATCH /rooms/:id/status")
+	}
+
+	status := RoomStatusVacantClean
+	newRoom.Status = &status
 
-	if result := storage.DB.Create(newRoom); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err := h.store.Transaction(func(tx Store) error {
+		if err := tx.Rooms().Create(newRoom); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionCreate, audit.EntityRoom, newRoom.ID, nil, newRoom)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(newRoom)
 }
 
-func Update(c fiber.Ctx) error {
-	newRoomInfo := make(map[string]any)
+// roomFields the fields of a room Update can change
+var roomFields = []string{"name", "category", "description", "price"}
+
+func (h *Handler) Update(c fiber.Ctx) error {
+	newRoomInfo := &validation.Partial{Value: new(Room)}
 	roomID, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fmt.Errorf("invalid room id"))
+		return apierror.InvalidID("room")
 	}
 
-	if err = c.Bind().JSON(&newRoomInfo); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+	if err = c.Bind().JSON(newRoomInfo); err != nil {
+		return apierror.InvalidBody(err)
 	}
 
-	room := new(Room)
-	room.ID = uint(roomID)
+	if _, ok := newRoomInfo.Values()["status"]; ok {
+		return apierror.Field("status", "a room's status is changed through PATCH /rooms/:id/status")
+	}
 
-	if result := storage.DB.Model(room).Updates(newRoomInfo); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	fields, err := newRoomInfo.Fields(roomFields...)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(room)
-}
+	err = h.store.Transaction(func(tx Store) error {
+		before, err := tx.Rooms().Find(uint(roomID))
+		if err != nil {
+			return err
+		}
 
-// SearchWithFilter params {filter: [name, category, status] , name, category, status }
-// getRoomByName ---
-// getRoomByCategory ---
-// getRoomByStatus ---
-func SearchWithFilter(c fiber.Ctx) error {
-	filter := c.Query("filter")
-	value := c.Query("value")
-
-	var rooms []Room
-
-	switch filter {
-	case "":
-		if result := storage.DB.Raw("SELECT * FROM rooms").Find(&rooms); result.Error != nil {
-			return c.Status(http.StatusInternalServerError).JSON(result.Error)
-		} else {
-			return c.Status(http.StatusOK).JSON(rooms)
+		if before.ID == 0 {
+			return apierror.NotFound("room")
 		}
-	default:
-		if result := storage.DB.Raw(fmt.Sprintf("SELECT * FROM rooms WHERE %s == ?", filter), value).Find(&rooms); result.Error != nil {
-			return c.Status(http.StatusInternalServerError).JSON(result.Error)
-		} else {
-			return c.Status(http.StatusOK).JSON(rooms)
+
+		if err = tx.Rooms().Update(before.ID, fields); err != nil {
+			return err
+		}
+
+		after, err := tx.Rooms().Find(before.ID)
+		if err != nil {
+			return err
 		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionUpdate, audit.EntityRoom, before.ID, before, after)
+	})
+	if err != nil {
+		return err
 	}
+
+	room := new(Room)
+	room.ID = uint(roomID)
+
+	return c.Status(http.StatusOK).JSON(room)
 }
 
-func GetById(c fiber.Ctx) error {
-	id := c.Params("id")
+// roomSearchParams the query parameters SearchWithFilter accepts
+var roomSearchParams = []string{"name", "category", "status", "minPrice", "maxPrice", "page", "page_size", "sort", "order"}
+
+// SearchWithFilter params {name, category, status, minPrice, maxPrice, page, page_size, sort, order}
+// a room has to match every filter given, any other parameter is rejected
+func (h *Handler) SearchWithFilter(c fiber.Ctx) error {
+	for param := range c.Queries() {
+		if !slices.Contains(roomSearchParams, param) {
+			return apierror.Field(param, fmt.Sprintf("unknown parameter %s, rooms can be searched by %s", param, strings.Join(roomSearchParams, ", ")))
+		}
+	}
+
+	filter := RoomFilter{
+		Name:     c.Query("name"),
+		Category: c.Query("category"),
+		Status:   c.Query("status"),
+	}
+
+	var err error
+	if filter.MinPrice, err = priceQuery(c, "minPrice"); err != nil {
+		return err
+	}
+
+	if filter.MaxPrice, err = priceQuery(c, "maxPrice"); err != nil {
+		return err
+	}
+
+	if filter.MaxPrice > 0 && filter.MaxPrice < filter.MinPrice {
+		return apierror.Field("maxPrice", "maxPrice must not be less than minPrice")
+	}
+
+	page, err := pagination.FromQuery(c, RoomSorts, "id", "asc")
+	if err != nil {
+		return err
+	}
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	rooms, total, err := h.store.Rooms().Search(filter, page)
+	if err != nil {
+		return err
 	}
 
-	var rooms []Room
+	return c.Status(http.StatusOK).JSON(pagination.New(rooms, total, page))
+}
 
-	if result := storage.DB.Raw("SELECT * FROM rooms WHERE id == ?", id).Find(&rooms); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+// priceQuery read a price query parameter, 0 when it isn't given
+func priceQuery(c fiber.Ctx, param string) (float64, error) {
+	value := c.Query(param)
+	if value == "" {
+		return 0, nil
 	}
 
-	return c.Status(http.StatusCreated).JSON(rooms)
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return 0, apierror.Field(param, param+" must be a number from 0")
+	}
+
+	return price, nil
 }
 
-func GetAllCategories(c fiber.Ctx) error {
-	var categories []string
+func (h *Handler) GetById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+
+	if err != nil {
+		return apierror.InvalidID("room")
+	}
+
+	r, err := h.store.Rooms().Find(uint(id))
+	if err != nil {
+		return err
+	}
+
+	if r.ID == 0 {
+		return apierror.NotFound("room")
+	}
+
+	return c.Status(http.StatusOK).JSON(r)
+}
 
-	if result := storage.DB.Raw("SELECT DISTINCT category FROM rooms").Find(&categories); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+func (h *Handler) GetAllCategories(c fiber.Ctx) error {
+	categories, err := h.store.Rooms().Categories()
+	if err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusOK).JSON(categories)
 }
+
+// GetAvailableRooms params {start, end, category, maxPrice}
+// get every room that is free for every night from start up to (not including) end, end defaults to the day after start
+func (h *Handler) GetAvailableRooms(c fiber.Ctx) error {
+	start, err := time.Parse(time.DateOnly, c.Query("start"))
+	if err != nil {
+		return apierror.Field("start", "invalid start date, expected YYYY-MM-DD")
+	}
+
+	end := start.AddDate(0, 0, 1)
+	if c.Query("end") != "" {
+		if end, err = time.Parse(time.DateOnly, c.Query("end")); err != nil {
+			return apierror.Field("end", "invalid end date, expected YYYY-MM-DD")
+		}
+	}
+
+	if !end.After(start) {
+		return apierror.Field("end", "end date must be after start date")
+	}
+
+	price := 0.0
+	if maxPrice := c.Query("maxPrice"); maxPrice != "" {
+		if price, err = strconv.ParseFloat(maxPrice, 64); err != nil || price <= 0 {
+			return apierror.Field("maxPrice", "invalid maxPrice")
+		}
+	}
+
+	rooms, err := h.store.Rooms().Available(start, end, c.Query("category"), price)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(rooms)
+}
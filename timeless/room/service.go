@@ -0,0 +1,887 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+)
+
+// BookingService the booking rules: pricing, clash detection, payments, check in and check out and cancelling.
+// errors a client can fix are *apierror.Error. every change is recorded in the audit log in its own transaction
+type BookingService struct {
+	store  Store
+	policy CancellationPolicy
+	// actor the user the changes are recorded against, 0 for the system
+	actor uint
+	// Now the current time, replaceable so the rules can be run at a fixed time
+	Now func() time.Time
+}
+
+// NewBookingService a BookingService working through store that cancels bookings under policy
+func NewBookingService(store Store, policy CancellationPolicy) *BookingService {
+	return &BookingService{
+		store:  store,
+		policy: policy,
+		Now:    func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// By a copy of the service that records its changes as made by the user actor
+func (s *BookingService) By(actor uint) *BookingService {
+	service := *s
+	service.actor = actor
+	return &service
+}
+
+// record write a change to the audit log of the transaction it was made in
+func (s *BookingService) record(tx Store, action, entity string, entityID uint, before, after any) error {
+	return tx.Audit().Record(s.actor, action, entity, entityID, before, after)
+}
+
+// Book check the room bookings of a new booking don't clash with any other, price every night and save it.
+// the clash check and the insert run in one transaction so that two receptionists booking the same room
+// at the same time can't both succeed
+func (s *BookingService) Book(booking *Booking) error {
+	return s.store.Transaction(func(tx Store) error {
+		totalAmount := 0.0
+
+		// nights taken by earlier room bookings in this same request
+		requestedNights := make(map[uint][]time.Time)
+
+		// check if the scheduled booking doesn't clash with another room booking
+		for i, roomBooking := range booking.RoomBookings {
+			r, err := tx.Rooms().Lock(roomBooking.RoomID)
+			if err != nil {
+				return err
+			}
+
+			if r.ID == 0 {
+				return apierror.Field(fmt.Sprintf("roomBookings[%d].roomID", i), fmt.Sprintf("room %d does not exist", roomBooking.RoomID))
+			}
+
+			if roomBooking.NumberOfNights == 0 {
+				return apierror.Field(fmt.Sprintf("roomBookings[%d].numberOfNights", i), "number of nights must be at least 1")
+			}
+
+			// the client sends the day of the first night, the stay runs from noon to noon
+			first := dateOnly(roomBooking.StartDate)
+			if roomBooking.StartDate.IsZero() {
+				first = dateOnly(s.Now())
+			}
+
+			start := first.Add(12 * time.Hour)
+			end := start.AddDate(0, 0, int(roomBooking.NumberOfNights))
+
+			dates, err := tx.Bookings().BookedNights(roomBooking.RoomID)
+			if err != nil {
+				return err
+			}
+
+			dates = append(dates, requestedNights[roomBooking.RoomID]...)
+
+			// get the individual dates
+			nights := stayNights(first, roomBooking.NumberOfNights)
+
+			if err = checkClash(r, dates, nights); err != nil {
+				return err
+			}
+
+			blocked, err := tx.Maintenance().BlockedNights(r.ID, 0)
+			if err != nil {
+				return err
+			}
+
+			if err = checkBlocked(r, blocked, nights); err != nil {
+				return err
+			}
+
+			requestedNights[roomBooking.RoomID] = append(requestedNights[roomBooking.RoomID], nights...)
+
+			roomBooking.StartDate = start
+			roomBooking.EndDate = end
+			roomBooking.Status = StayStatusReserved
+			roomBooking.CheckedInAt = nil
+			roomBooking.CheckedOutAt = nil
+
+			// an amount sent by the client is a flat nightly rate, otherwise every night is priced from the rate plans
+			roomBooking.Nights, err = priceNights(tx.RatePlans(), r, nights, roomBooking.Amount, true)
+			if err != nil {
+				return err
+			}
+
+			amount := averageRate(roomBooking.Nights)
+			roomBooking.Amount = &amount
+
+			totalAmount += nightsTotal(roomBooking.Nights)
+		}
+
+		booking.Amount = &totalAmount
+
+		// a booking marked paid when it is made is paid in full with its payment method,
+		// otherwise any payments sent with it (e.g. a deposit) are recorded
+		if booking.IsPaid && len(booking.Payments) == 0 {
+			booking.Payments = []*Payment{{Amount: totalAmount, Method: booking.PaymentMethod}}
+		}
+
+		for _, payment := range booking.Payments {
+			if err := s.validatePayment(payment); err != nil {
+				return err
+			}
+		}
+
+		booking.computeBalance()
+		if booking.Balance < 0 {
+			return apierror.Field("payments", "payments are more than the booking amount")
+		}
+
+		booking.IsPaid = booking.Balance <= 0
+
+		if err := tx.Bookings().Create(booking); err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionCreate, audit.EntityBooking, booking.ID, nil, booking)
+	})
+}
+
+// checkClash refuse nights that are already booked in room r
+func checkClash(r Room, booked []time.Time, nights []time.Time) error {
+	for _, night := range nights {
+		if slices.Contains(booked, night) {
+			year, month, day := night.Date()
+			return apierror.Conflict(fmt.Sprintf("room number %s is booked on %d/%d/%d", roomName(r), day, month, year))
+		}
+	}
+
+	return nil
+}
+
+// Update move the dates of a room booking and change the booking's details, the nights are priced again. same as
+// Book, the moved nights can't clash with another booking or a maintenance block
+func (s *BookingService) Update(bookingID, roomBookingID uint, request *UpdateBookingRequest) error {
+	if request.NumberOfNights == 0 {
+		return apierror.Field("numberOfNights", "number of nights must be at least 1")
+	}
+
+	// the client sends the day of the first night, the stay runs from noon to noon
+	first := dateOnly(request.StartDate)
+	start := first.Add(12 * time.Hour)
+	end := start.AddDate(0, 0, int(request.NumberOfNights))
+
+	return s.store.Transaction(func(tx Store) error {
+		roomBooking, err := tx.Bookings().LockRoomBooking(roomBookingID)
+		if err != nil {
+			return err
+		}
+
+		if roomBooking.ID == 0 || roomBooking.BookingID != bookingID {
+			return apierror.NotFound("room booking")
+		}
+
+		if !roomBooking.holdsRoom() {
+			return apierror.Conflict(fmt.Sprintf("can't change a stay that is %s", roomBooking.Status))
+		}
+
+		if roomBooking.Status == StayStatusCheckedIn && !first.Equal(roomBooking.firstNight()) {
+			return apierror.Conflict("can't move the first night of a stay the guest is checked into")
+		}
+
+		before, err := tx.Bookings().FindFolio(bookingID)
+		if err != nil {
+			return err
+		}
+
+		r, err := tx.Rooms().Lock(roomBooking.RoomID)
+		if err != nil {
+			return err
+		}
+
+		// the nights the stay holds now are free for it to move into
+		held := stayNights(roomBooking.firstNight(), roomBooking.NumberOfNights)
+
+		dates, err := tx.Bookings().BookedNights(r.ID)
+		if err != nil {
+			return err
+		}
+
+		dates = slices.DeleteFunc(dates, func(night time.Time) bool { return slices.Contains(held, night) })
+
+		nights := stayNights(first, request.NumberOfNights)
+
+		if err = checkClash(r, dates, nights); err != nil {
+			return err
+		}
+
+		blocked, err := tx.Maintenance().BlockedNights(r.ID, 0)
+		if err != nil {
+			return err
+		}
+
+		if err = checkBlocked(r, blocked, nights); err != nil {
+			return err
+		}
+
+		// only these can change, the booking's receptionist and the room are kept
+		bookingUpdates := map[string]interface{}{
+			"CustomerID":      request.CustomerID,
+			"PaymentMethod":   request.PaymentMethod,
+			"IsComplementary": request.IsComplementary,
+		}
+
+		if err = tx.Bookings().Update(bookingID, bookingUpdates); err != nil {
+			return err
+		}
+
+		roomBookingUpdates := map[string]interface{}{
+			"StartDate":      start,
+			"EndDate":        end,
+			"NumberOfNights": request.NumberOfNights,
+		}
+
+		if err = tx.Bookings().UpdateRoomBooking(roomBooking.ID, roomBookingUpdates); err != nil {
+			return err
+		}
+
+		// the nights moved, so price them again. an amount sent by the client stays a flat nightly rate
+		if err = repriceRoomBooking(tx, roomBooking.ID, request.Amount); err != nil {
+			return err
+		}
+
+		if err = updateBookingAmount(tx, bookingID); err != nil {
+			return err
+		}
+
+		after, err := tx.Bookings().FindFolio(bookingID)
+		if err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionUpdate, audit.EntityBooking, bookingID, before, after)
+	})
+}
+
+// Extend add nights to the end of a room booking at the current rates. same as Book, the clash check
+// and the update share one transaction
+func (s *BookingService) Extend(roomBookingID uint, numberOfNights uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+
+	if numberOfNights == 0 {
+		return roomBooking, apierror.Field("numberOfNights", "number of nights must be at least 1")
+	}
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if roomBooking, err = tx.Bookings().FindRoomBooking(roomBookingID); err != nil {
+			return err
+		}
+
+		if roomBooking.ID == 0 {
+			return apierror.NotFound("room booking")
+		}
+
+		if !roomBooking.holdsRoom() {
+			return apierror.Conflict(fmt.Sprintf("can't extend a stay that is %s", roomBooking.Status))
+		}
+
+		before := roomBooking
+
+		r, err := tx.Rooms().Lock(roomBooking.RoomID)
+		if err != nil {
+			return err
+		}
+
+		// the extra nights start on the day the guest was due to leave
+		nights := stayNights(dateOnly(roomBooking.EndDate), numberOfNights)
+
+		dates, err := tx.Bookings().BookedNights(roomBooking.RoomID)
+		if err != nil {
+			return err
+		}
+
+		if err = checkClash(r, dates, nights); err != nil {
+			return err
+		}
+
+		blocked, err := tx.Maintenance().BlockedNights(r.ID, 0)
+		if err != nil {
+			return err
+		}
+
+		if err = checkBlocked(r, blocked, nights); err != nil {
+			return err
+		}
+
+		if roomBooking.Nights, err = tx.Bookings().Nights(roomBooking.ID); err != nil {
+			return err
+		}
+
+		// the extra nights are charged at the current rates
+		newNights, err := priceNights(tx.RatePlans(), r, nights, nil, false)
+		if err != nil {
+			return err
+		}
+
+		// room bookings made before per night pricing keep their flat rate for the nights already booked
+		if len(roomBooking.Nights) == 0 {
+			rate := r.Price
+			if roomBooking.Amount != nil {
+				rate = *roomBooking.Amount
+			}
+
+			bookedNights, err := priceNights(tx.RatePlans(), r, stayNights(dateOnly(roomBooking.StartDate), roomBooking.NumberOfNights), &rate, false)
+			if err != nil {
+				return err
+			}
+
+			newNights = append(bookedNights, newNights...)
+		}
+
+		for _, night := range newNights {
+			night.RoomBookingID = roomBooking.ID
+		}
+
+		if err = tx.Bookings().AddNights(newNights); err != nil {
+			return err
+		}
+
+		roomBooking.Nights = append(roomBooking.Nights, newNights...)
+
+		amount := averageRate(roomBooking.Nights)
+		roomBooking.Amount = &amount
+		roomBooking.NumberOfNights += numberOfNights
+		roomBooking.EndDate = roomBooking.EndDate.AddDate(0, 0, int(numberOfNights))
+
+		updates := map[string]interface{}{
+			"NumberOfNights": roomBooking.NumberOfNights,
+			"EndDate":        roomBooking.EndDate,
+			"Amount":         roomBooking.Amount,
+		}
+
+		if err = tx.Bookings().UpdateRoomBooking(roomBooking.ID, updates); err != nil {
+			return err
+		}
+
+		if err = updateBookingAmount(tx, roomBooking.BookingID); err != nil {
+			return err
+		}
+
+		after, err := tx.Bookings().FindRoomBooking(roomBooking.ID)
+		if err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionExtend, audit.EntityRoomBooking, roomBooking.ID, before, after)
+	})
+
+	return roomBooking, err
+}
+
+// CheckIn check a guest into a reserved room booking on one of the nights of their stay, the room has to have
+// been inspected and becomes occupied
+func (s *BookingService) CheckIn(roomBookingID uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+
+	err := s.store.Transaction(func(tx Store) error {
+		before, err := tx.Bookings().FindRoomBooking(roomBookingID)
+		if err != nil {
+			return err
+		}
+
+		if before.ID == 0 {
+			return apierror.NotFound("room booking")
+		}
+
+		if err = before.canMoveTo(StayStatusCheckedIn); err != nil {
+			return err
+		}
+
+		now := s.Now()
+		if today := dateOnly(now); today.Before(before.firstNight()) || today.After(before.lastNight()) {
+			year, month, day := before.firstNight().Date()
+			return apierror.Conflict(fmt.Sprintf("the stay starts on %d/%d/%d for %d nights, the guest can only be checked in during it", day, month, year, before.NumberOfNights))
+		}
+
+		r, err := tx.Rooms().Lock(before.RoomID)
+		if err != nil {
+			return err
+		}
+
+		if r.status() != RoomStatusInspected {
+			return apierror.Conflict(fmt.Sprintf("room %s is %s, a guest can only be checked into an inspected room", roomName(r), r.status()))
+		}
+
+		updates := map[string]interface{}{
+			"Status":      StayStatusCheckedIn,
+			"CheckedInAt": now,
+		}
+
+		if err = tx.Bookings().UpdateRoomBooking(roomBookingID, updates); err != nil {
+			return err
+		}
+
+		if roomBooking, err = tx.Bookings().FindRoomBooking(roomBookingID); err != nil {
+			return err
+		}
+
+		if _, err = moveRoom(tx, s.actor, r, RoomStatusOccupied); err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionCheckIn, audit.EntityRoomBooking, roomBooking.ID, before, roomBooking)
+	})
+
+	return roomBooking, err
+}
+
+// CheckOutOverride a request to check a guest out even though the folio has an unpaid balance
+type CheckOutOverride struct {
+	By      uint // the user overriding
+	Allowed bool // whether they hold the checkout:override permission
+}
+
+// CheckOut check a guest out of a room booking they are checked into, refused while the booking's folio has an unpaid balance
+// unless override is given and allowed. the room is left dirty with a task to clean it
+func (s *BookingService) CheckOut(roomBookingID uint, override *CheckOutOverride) (RoomBookings, error) {
+	var roomBooking RoomBookings
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if roomBooking, err = tx.Bookings().FindRoomBooking(roomBookingID); err != nil {
+			return err
+		}
+
+		if roomBooking.ID == 0 {
+			return apierror.NotFound("room booking")
+		}
+
+		if err = roomBooking.canMoveTo(StayStatusCheckedOut); err != nil {
+			return err
+		}
+
+		before := roomBooking
+
+		booking, err := tx.Bookings().Find(roomBooking.BookingID)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.Bookings().LoadFolio(&booking); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"Status":       StayStatusCheckedOut,
+			"CheckedOutAt": s.Now(),
+		}
+
+		if booking.Balance > 0 {
+			if override == nil {
+				return apierror.Conflict(fmt.Sprintf("folio has an unpaid balance of %.2f", booking.Balance))
+			}
+
+			if !override.Allowed {
+				return apierror.Forbidden("only a manager can check out a guest with an unpaid balance")
+			}
+
+			updates["CheckOutOverriddenBy"] = override.By
+		}
+
+		if err = tx.Bookings().UpdateRoomBooking(roomBooking.ID, updates); err != nil {
+			return err
+		}
+
+		if roomBooking, err = tx.Bookings().FindRoomBooking(roomBooking.ID); err != nil {
+			return err
+		}
+
+		if err = s.record(tx, audit.ActionCheckOut, audit.EntityRoomBooking, roomBooking.ID, before, roomBooking); err != nil {
+			return err
+		}
+
+		return leaveRoom(tx, s.actor, roomBooking)
+	})
+
+	return roomBooking, err
+}
+
+// Cancel cancel a booking as the service's actor, apply the cancellation policy and record the refund owed. its
+// reserved stays are cancelled, no shows stay no shows
+func (s *BookingService) Cancel(bookingID uint, request CancelBookingRequest) (Booking, error) {
+	var booking Booking
+
+	if request.Reason == "" {
+		return booking, apierror.Field("reason", "a cancellation reason is required")
+	}
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if booking, err = tx.Bookings().FindWithRooms(bookingID); err != nil {
+			return err
+		}
+
+		if booking.ID == 0 {
+			return apierror.NotFound("booking")
+		}
+
+		if booking.Status == BookingStatusCancelled {
+			return apierror.Conflict("booking has already been cancelled")
+		}
+
+		for _, roomBooking := range booking.RoomBookings {
+			if roomBooking.Status == StayStatusCheckedIn || roomBooking.Status == StayStatusCheckedOut {
+				return apierror.Conflict("can't cancel a booking after the guest has checked in")
+			}
+		}
+
+		now := s.Now()
+
+		fee := 0.0
+		if !booking.IsComplementary {
+			fee = s.policy.Fee(booking.RoomBookings, now)
+		}
+
+		if err = tx.Bookings().LoadFolio(&booking); err != nil {
+			return err
+		}
+
+		before := booking
+
+		// charges posted to the folio are still owed
+		refund := max(booking.AmountPaid-fee-booking.ChargesTotal, 0)
+
+		booking.Status = BookingStatusCancelled
+		booking.CancelledAt = &now
+		// nil when the system cancels it
+		booking.CancelledBy = nil
+		if actor := s.actor; actor != 0 {
+			booking.CancelledBy = &actor
+		}
+		booking.CancellationReason = &request.Reason
+		booking.CancellationFee = &fee
+		booking.RefundAmount = &refund
+
+		updates := map[string]interface{}{
+			"Status":             booking.Status,
+			"CancelledAt":        booking.CancelledAt,
+			"CancelledBy":        booking.CancelledBy,
+			"CancellationReason": booking.CancellationReason,
+			"CancellationFee":    booking.CancellationFee,
+			"RefundAmount":       booking.RefundAmount,
+		}
+
+		if err = tx.Bookings().Update(booking.ID, updates); err != nil {
+			return err
+		}
+
+		// new copies of the stays, before keeps them as they were
+		stays := make([]*RoomBookings, 0, len(booking.RoomBookings))
+		for _, roomBooking := range booking.RoomBookings {
+			stay := *roomBooking
+			if stay.Status == StayStatusReserved {
+				stay.Status = StayStatusCancelled
+				if err = tx.Bookings().UpdateRoomBooking(stay.ID, map[string]any{"Status": stay.Status}); err != nil {
+					return err
+				}
+			}
+
+			stays = append(stays, &stay)
+		}
+		booking.RoomBookings = stays
+
+		booking.computeBalance()
+		return s.record(tx, audit.ActionCancel, audit.EntityBooking, booking.ID, before, booking)
+	})
+
+	return booking, err
+}
+
+// MarkPaid mark a booking paid by recording a payment of its outstanding balance
+func (s *BookingService) MarkPaid(bookingID uint, method, reference string) (Booking, error) {
+	var booking Booking
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if booking, err = tx.Bookings().Find(bookingID); err != nil {
+			return err
+		}
+
+		if booking.ID == 0 {
+			return apierror.NotFound("booking")
+		}
+
+		if err = tx.Bookings().LoadFolio(&booking); err != nil {
+			return err
+		}
+
+		before := booking
+
+		if booking.Balance > 0 {
+			payment := &Payment{
+				BookingID: booking.ID,
+				Amount:    booking.Balance,
+				Method:    method,
+			}
+
+			if reference != "" {
+				payment.Reference = &reference
+			}
+
+			if err = s.validatePayment(payment); err != nil {
+				return err
+			}
+
+			if err = tx.Bookings().AddPayment(payment); err != nil {
+				return err
+			}
+
+			booking.Payments = append(booking.Payments, payment)
+			booking.computeBalance()
+		}
+
+		booking.IsPaid = true
+		if err = tx.Bookings().Update(booking.ID, map[string]any{"is_paid": true}); err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionMarkPaid, audit.EntityBooking, booking.ID, before, booking)
+	})
+
+	return booking, err
+}
+
+// AddPayment record a payment against a booking, it can't be more than the outstanding balance
+func (s *BookingService) AddPayment(bookingID uint, payment *Payment) (Booking, error) {
+	var booking Booking
+
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		if booking, err = tx.Bookings().Find(bookingID); err != nil {
+			return err
+		}
+
+		if booking.ID == 0 {
+			return apierror.NotFound("booking")
+		}
+
+		if err = s.validatePayment(payment); err != nil {
+			return err
+		}
+
+		if err = tx.Bookings().LoadFolio(&booking); err != nil {
+			return err
+		}
+
+		if payment.Amount > booking.Balance {
+			return apierror.Field("amount", fmt.Sprintf("payment of %.2f is more than the outstanding balance of %.2f", payment.Amount, booking.Balance))
+		}
+
+		payment.BookingID = booking.ID
+		if err = tx.Bookings().AddPayment(payment); err != nil {
+			return err
+		}
+
+		if err = s.record(tx, audit.ActionCreate, audit.EntityPayment, payment.ID, nil, payment); err != nil {
+			return err
+		}
+
+		booking.Payments = append(booking.Payments, payment)
+		booking.computeBalance()
+		booking.IsPaid = booking.Balance <= 0
+
+		return tx.Bookings().Update(booking.ID, map[string]any{"is_paid": booking.IsPaid})
+	})
+
+	return booking, err
+}
+
+// PostCharge post an incidental charge to a booking's folio as the service's actor
+func (s *BookingService) PostCharge(bookingID uint, charge *Charge) error {
+	charge.Category = strings.ToLower(charge.Category)
+	if !slices.Contains(ChargeCategories, charge.Category) {
+		return apierror.Field("category", "charge category must be one of "+strings.Join(ChargeCategories, ", "))
+	}
+
+	if charge.UnitPrice <= 0 {
+		return apierror.Field("unitPrice", "charge unit price must be greater than 0")
+	}
+
+	if charge.Quantity == 0 {
+		charge.Quantity = 1
+	}
+
+	charge.Amount = charge.UnitPrice * float64(charge.Quantity)
+
+	return s.store.Transaction(func(tx Store) error {
+		booking, err := tx.Bookings().Find(bookingID)
+		if err != nil {
+			return err
+		}
+
+		if booking.ID == 0 {
+			return apierror.NotFound("booking")
+		}
+
+		if booking.Status == BookingStatusCancelled {
+			return apierror.Conflict("can't post charges to a cancelled booking")
+		}
+
+		if charge.RoomBookingID != nil {
+			roomBooking, err := tx.Bookings().FindRoomBookingOf(booking.ID, *charge.RoomBookingID)
+			if err != nil {
+				return err
+			}
+
+			if roomBooking.ID == 0 {
+				return apierror.Field("roomBookingID", "room booking is not part of this booking")
+			}
+		}
+
+		charge.PostedBy = s.actor
+		charge.BookingID = booking.ID
+		if err = tx.Bookings().AddCharge(charge); err != nil {
+			return err
+		}
+
+		if err = s.record(tx, audit.ActionCreate, audit.EntityCharge, charge.ID, nil, charge); err != nil {
+			return err
+		}
+
+		return refreshPaymentStatus(tx, booking.ID)
+	})
+}
+
+// VoidCharge remove a charge posted to a booking's folio by mistake
+func (s *BookingService) VoidCharge(bookingID, chargeID uint) error {
+	return s.store.Transaction(func(tx Store) error {
+		charge, err := tx.Bookings().FindCharge(bookingID, chargeID)
+		if err != nil {
+			return err
+		}
+
+		if charge.ID == 0 {
+			return apierror.NotFound("charge")
+		}
+
+		if _, err = tx.Bookings().VoidCharge(bookingID, chargeID); err != nil {
+			return err
+		}
+
+		if err = s.record(tx, audit.ActionVoid, audit.EntityCharge, charge.ID, charge, nil); err != nil {
+			return err
+		}
+
+		return refreshPaymentStatus(tx, bookingID)
+	})
+}
+
+// Delete delete a booking, its folio is kept in the audit log
+func (s *BookingService) Delete(bookingID uint) error {
+	return s.store.Transaction(func(tx Store) error {
+		booking, err := tx.Bookings().FindFolio(bookingID)
+		if err != nil {
+			return err
+		}
+
+		if booking.ID == 0 {
+			return apierror.NotFound("booking")
+		}
+
+		if err = tx.Bookings().Delete(booking.ID); err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionDelete, audit.EntityBooking, booking.ID, booking, nil)
+	})
+}
+
+// validatePayment check a payment before it is recorded and fill in its defaults, it is always recorded as taken by
+// the service's actor whatever the client sent
+func (s *BookingService) validatePayment(payment *Payment) error {
+	if payment.Amount <= 0 {
+		return apierror.Field("amount", "payment amount must be greater than 0")
+	}
+
+	if payment.Method == "" {
+		return apierror.Field("method", "payment method is required")
+	}
+
+	if payment.PaidAt.IsZero() {
+		payment.PaidAt = s.Now()
+	}
+
+	payment.Receptionist = s.actor
+
+	return nil
+}
+
+// updateBookingAmount recompute the total of a booking from its room bookings
+func updateBookingAmount(tx Store, bookingID uint) error {
+	roomBookings, err := tx.Bookings().RoomBookingsOf(bookingID)
+	if err != nil {
+		return err
+	}
+
+	totalAmount := 0.0
+	for _, roomBooking := range roomBookings {
+		totalAmount += roomBooking.Total()
+	}
+
+	if err = tx.Bookings().Update(bookingID, map[string]any{"amount": totalAmount}); err != nil {
+		return err
+	}
+
+	return refreshPaymentStatus(tx, bookingID)
+}
+
+// refreshPaymentStatus keep the stored IsPaid flag in line with the payments after the amount or the payments change
+func refreshPaymentStatus(tx Store, bookingID uint) error {
+	booking, err := tx.Bookings().Find(bookingID)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Bookings().LoadFolio(&booking); err != nil {
+		return err
+	}
+
+	return tx.Bookings().Update(bookingID, map[string]any{"is_paid": booking.Balance <= 0})
+}
+
+// repriceRoomBooking replace the priced nights of a room booking after its dates changed
+func repriceRoomBooking(tx Store, roomBookingID uint, flatRate *float64) error {
+	roomBooking, err := tx.Bookings().FindRoomBooking(roomBookingID)
+	if err != nil {
+		return err
+	}
+
+	r, err := tx.Rooms().Find(roomBooking.RoomID)
+	if err != nil {
+		return err
+	}
+
+	nights, err := priceNights(tx.RatePlans(), r, stayNights(dateOnly(roomBooking.StartDate), roomBooking.NumberOfNights), flatRate, false)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Bookings().ReplaceNights(roomBookingID, nights); err != nil {
+		return err
+	}
+
+	return tx.Bookings().UpdateRoomBooking(roomBookingID, map[string]any{"amount": averageRate(nights)})
+}
+
+// stayNights get the individual nights of a stay starting on the night of first
+func stayNights(first time.Time, numberOfNights uint) []time.Time {
+	nights := make([]time.Time, 0, numberOfNights)
+
+	for i := uint(0); i < numberOfNights; i++ {
+		nights = append(nights, first.AddDate(0, 0, int(i)))
+	}
+
+	return nights
+}
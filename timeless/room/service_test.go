@@ -0,0 +1,435 @@
+package room
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/storage/storagetest"
+	"gorm.io/gorm"
+)
+
+// newService a BookingService over a new database holding rooms 101 (standard at 100) and 102 (deluxe at 200)
+// and values, it runs at 8 in the morning of the 1st of march
+func newService(t *testing.T, values ...any) (*BookingService, *gorm.DB) {
+	t.Helper()
+
+	db := storagetest.New(t)
+	storagetest.Create(t, db, newRoom("101", "standard", 100), newRoom("102", "deluxe", 200))
+	storagetest.Create(t, db, values...)
+
+	service := NewBookingService(NewStore(db), DefaultPolicy)
+	service.Now = func() time.Time { return time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC) }
+
+	return service, db
+}
+
+// requestStay a stay of a booking request, in roomID from the night of first for nights
+func requestStay(roomID uint, first, nights int) *RoomBookings {
+	return &RoomBookings{RoomID: roomID, StartDate: dateOnly(day(first)), NumberOfNights: uint(nights)}
+}
+
+// errorCode the apierror code of err, "" when there is no error
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+
+	if err == nil {
+		return ""
+	}
+
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return apiErr.Code
+}
+
+// rates the rate of each night in order
+func rates(nights []*RoomBookingNight) []float64 {
+	r := make([]float64, 0, len(nights))
+	for _, night := range nights {
+		r = append(r, night.Rate)
+	}
+
+	return r
+}
+
+func TestBookClash(t *testing.T) {
+	tests := []struct {
+		name  string
+		stays []*RoomBookings
+		want  string
+	}{
+		{"a booked night", []*RoomBookings{requestStay(1, 4, 2)}, apierror.CodeConflict},
+		{"leaving the day another stay starts", []*RoomBookings{requestStay(1, 1, 2)}, ""},
+		{"arriving the day another stay ends", []*RoomBookings{requestStay(1, 5, 1)}, ""},
+		{"a night freed by a cancelled stay", []*RoomBookings{requestStay(1, 6, 1)}, ""},
+		{"the same room twice in one booking", []*RoomBookings{requestStay(1, 7, 2), requestStay(1, 8, 1)}, apierror.CodeConflict},
+		{"a night the room is out of service", []*RoomBookings{requestStay(2, 9, 2)}, apierror.CodeConflict},
+		{"a room that doesn't exist", []*RoomBookings{requestStay(9, 1, 1)}, apierror.CodeValidation},
+		{"no nights", []*RoomBookings{requestStay(1, 1, 0)}, apierror.CodeValidation},
+	}
+
+	for _, test := range tests {
+		service, db := newService(t,
+			newBooking("cash", newStay(1, 3, 2, 100, StayStatusReserved)),
+			newBooking("cash", newStay(1, 6, 1, 100, StayStatusCancelled)),
+			&MaintenanceBlock{RoomID: 2, StartDate: dateOnly(day(10)), EndDate: dateOnly(day(10)), Reason: "leak"},
+		)
+
+		err := service.Book(&Booking{PaymentMethod: "cash", RoomBookings: test.stays})
+		if got := errorCode(t, err); got != test.want {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.want, err)
+		}
+
+		var bookings int64
+		if err = db.Model(&Booking{}).Count(&bookings).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// the two bookings already there, and the new one when it was let through
+		want := int64(2)
+		if test.want == "" {
+			want = 3
+		}
+
+		if bookings != want {
+			t.Errorf("%s: %d bookings saved, want %d", test.name, bookings, want)
+		}
+	}
+}
+
+func TestBookPricing(t *testing.T) {
+	march, offer, longStays := "march", "101 offer", "long stays"
+	standard, deluxe := "standard", "deluxe"
+	room101 := uint(1)
+	first, last := dateOnly(day(1)), dateOnly(day(31))
+	offerFirst, offerLast := dateOnly(day(10)), dateOnly(day(12))
+	flat := 90.0
+
+	tests := []struct {
+		name   string
+		stay   *RoomBookings
+		amount *float64
+		want   []float64
+		code   string
+	}{
+		{"week nights of a plan", requestStay(1, 2, 2), nil, []float64{150, 150}, ""},
+		{"weekend uplift on friday and saturday", requestStay(1, 5, 3), nil, []float64{150, 180, 180}, ""},
+		{"a room's own plan before its category's", requestStay(1, 9, 2), nil, []float64{150, 80}, ""},
+		{"room price after the plan ends", requestStay(1, 31, 2), nil, []float64{150, 100}, ""},
+		{"a flat amount over the plans", requestStay(1, 5, 2), &flat, []float64{90, 90}, ""},
+		{"short of a minimum stay", requestStay(2, 2, 2), nil, nil, apierror.CodeValidation},
+		{"a minimum stay met", requestStay(2, 2, 3), nil, []float64{250, 250, 250}, ""},
+	}
+
+	for _, test := range tests {
+		service, _ := newService(t,
+			&RatePlan{Name: &march, Category: &standard, StartDate: &first, EndDate: &last, Price: 150, WeekendUplift: 20},
+			&RatePlan{Name: &offer, RoomID: &room101, StartDate: &offerFirst, EndDate: &offerLast, Price: 80, Priority: 1},
+			&RatePlan{Name: &longStays, Category: &deluxe, Price: 250, MinimumStay: 3},
+		)
+
+		test.stay.Amount = test.amount
+		booking := &Booking{PaymentMethod: "cash", RoomBookings: []*RoomBookings{test.stay}}
+
+		err := service.Book(booking)
+		if got := errorCode(t, err); got != test.code {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.code, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if got := rates(booking.RoomBookings[0].Nights); !slices.Equal(got, test.want) {
+			t.Errorf("%s: nights at %v, want %v", test.name, got, test.want)
+		}
+
+		total := 0.0
+		for _, rate := range test.want {
+			total += rate
+		}
+
+		if *booking.Amount != total || *booking.RoomBookings[0].Amount != total/float64(len(test.want)) {
+			t.Errorf("%s: booking for %v at %v a night, want %v at %v", test.name, *booking.Amount, *booking.RoomBookings[0].Amount, total, total/float64(len(test.want)))
+		}
+	}
+}
+
+func TestExtend(t *testing.T) {
+	standard, march := "standard", "march"
+	first := dateOnly(day(4))
+
+	tests := []struct {
+		name          string
+		roomBookingID uint
+		nights        uint
+		want          []float64
+		code          string
+	}{
+		{"nights at the current rates", 1, 2, []float64{100, 100, 120, 120}, ""},
+		{"into a booked night", 1, 3, nil, apierror.CodeConflict},
+		{"a stay that is over", 3, 1, nil, apierror.CodeConflict},
+		{"no nights", 1, 0, nil, apierror.CodeValidation},
+		{"a room booking that doesn't exist", 9, 1, nil, apierror.CodeNotFound},
+	}
+
+	for _, test := range tests {
+		// the stay being extended was priced before the march plan started
+		service, db := newService(t,
+			newBooking("cash", newStay(1, 2, 2, 100, StayStatusReserved)),
+			newBooking("cash", newStay(1, 6, 1, 100, StayStatusReserved)),
+			newBooking("cash", newStay(2, 1, 1, 200, StayStatusCheckedOut)),
+			&RatePlan{Name: &march, Category: &standard, StartDate: &first, Price: 120},
+		)
+
+		roomBooking, err := service.Extend(test.roomBookingID, test.nights)
+		if got := errorCode(t, err); got != test.code {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.code, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if got := rates(roomBooking.Nights); !slices.Equal(got, test.want) {
+			t.Errorf("%s: nights at %v, want %v", test.name, got, test.want)
+		}
+
+		if !roomBooking.EndDate.Equal(day(6)) || roomBooking.NumberOfNights != 4 {
+			t.Errorf("%s: %d nights to %v, want 4 to %v", test.name, roomBooking.NumberOfNights, roomBooking.EndDate, day(6))
+		}
+
+		booking, err := NewStore(db).Bookings().Find(roomBooking.BookingID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *booking.Amount != 440 {
+			t.Errorf("%s: booking amount %v, want 440", test.name, *booking.Amount)
+		}
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		stay       *RoomBookings
+		paid       float64
+		code       string
+		fee        float64
+		refund     float64
+		stayStatus string
+	}{
+		// the service runs on the morning of the 1st, 2 days before noon on the 3rd
+		{"before the free window", newStay(1, 3, 2, 100, StayStatusReserved), 200, "", 0, 200, StayStatusCancelled},
+		{"inside the free window", newStay(1, 2, 2, 100, StayStatusReserved), 200, "", 100, 100, StayStatusCancelled},
+		{"inside the free window with nothing paid", newStay(1, 2, 2, 100, StayStatusReserved), 0, "", 100, 0, StayStatusCancelled},
+		{"a no show", newStay(1, 1, 2, 100, StayStatusNoShow), 50, "", 100, 0, StayStatusNoShow},
+		{"after the guest checked in", newStay(1, 1, 2, 100, StayStatusCheckedIn), 200, apierror.CodeConflict, 0, 0, StayStatusCheckedIn},
+	}
+
+	for _, test := range tests {
+		booking := newBooking("cash", test.stay)
+		if test.paid > 0 {
+			booking.Payments = []*Payment{{Amount: test.paid, Method: "cash", PaidAt: day(1)}}
+		}
+
+		service, db := newService(t, booking)
+
+		cancelled, err := service.By(7).Cancel(booking.ID, CancelBookingRequest{Reason: "plans changed"})
+		if got := errorCode(t, err); got != test.code {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.code, err)
+			continue
+		}
+
+		if err == nil {
+			if *cancelled.CancellationFee != test.fee || *cancelled.RefundAmount != test.refund {
+				t.Errorf("%s: fee %v and refund %v, want %v and %v", test.name, *cancelled.CancellationFee, *cancelled.RefundAmount, test.fee, test.refund)
+			}
+
+			if cancelled.Status != BookingStatusCancelled {
+				t.Errorf("%s: booking %s, want %s", test.name, cancelled.Status, BookingStatusCancelled)
+			}
+
+			if cancelled.CancelledBy == nil || *cancelled.CancelledBy != 7 {
+				t.Errorf("%s: cancelled by %v, want user 7", test.name, cancelled.CancelledBy)
+			}
+		}
+
+		stay, err := NewStore(db).Bookings().FindRoomBooking(test.stay.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stay.Status != test.stayStatus {
+			t.Errorf("%s: stay %s, want %s", test.name, stay.Status, test.stayStatus)
+		}
+	}
+}
+
+func TestCheckOutBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		paid     float64
+		override *CheckOutOverride
+		code     string
+	}{
+		{"paid in full", StayStatusCheckedIn, 200, nil, ""},
+		{"an unpaid balance", StayStatusCheckedIn, 150, nil, apierror.CodeConflict},
+		{"an override without the permission", StayStatusCheckedIn, 150, &CheckOutOverride{By: 3, Allowed: false}, apierror.CodeForbidden},
+		{"an override by a manager", StayStatusCheckedIn, 150, &CheckOutOverride{By: 3, Allowed: true}, ""},
+		{"a guest that isn't checked in", StayStatusReserved, 200, nil, apierror.CodeConflict},
+	}
+
+	for _, test := range tests {
+		booking := newBooking("cash", newStay(1, 1, 2, 100, test.status))
+		booking.Payments = []*Payment{{Amount: test.paid, Method: "cash", PaidAt: day(1)}}
+
+		service, db := newService(t, booking)
+		if err := db.Model(&Room{}).Where("id = ?", 1).Update("status", RoomStatusOccupied).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		roomBooking, err := service.CheckOut(booking.RoomBookings[0].ID, test.override)
+		if got := errorCode(t, err); got != test.code {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.code, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if roomBooking.Status != StayStatusCheckedOut {
+			t.Errorf("%s: stay %s, want %s", test.name, roomBooking.Status, StayStatusCheckedOut)
+		}
+
+		overriddenBy := roomBooking.CheckOutOverriddenBy
+		if wantOverride := test.override != nil; (overriddenBy != nil) != wantOverride || wantOverride && *overriddenBy != test.override.By {
+			t.Errorf("%s: overridden by %v, want %v", test.name, overriddenBy, test.override)
+		}
+	}
+}
+
+// TestPaymentReceptionist payments are recorded as taken by whoever records them, never by who the client says
+func TestPaymentReceptionist(t *testing.T) {
+	booking := newBooking("cash", newStay(1, 1, 2, 100, StayStatusReserved))
+	booking.Receptionist = 3
+
+	service, _ := newService(t, booking)
+
+	paid, err := service.By(7).AddPayment(booking.ID, &Payment{Amount: 50, Method: "transfer", Receptionist: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paid, err = service.By(8).MarkPaid(booking.ID, "cash", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var receptionists []uint
+	for _, payment := range paid.Payments {
+		receptionists = append(receptionists, payment.Receptionist)
+	}
+
+	if want := []uint{7, 8}; !slices.Equal(receptionists, want) {
+		t.Errorf("payments taken by %v, want %v", receptionists, want)
+	}
+}
+
+// TestChargePostedBy charges are recorded as posted by whoever posts them, never by who the client says
+func TestChargePostedBy(t *testing.T) {
+	booking := newBooking("cash", newStay(1, 1, 2, 100, StayStatusCheckedIn))
+	booking.Receptionist = 3
+
+	service, _ := newService(t, booking)
+
+	charge := &Charge{Category: ChargeCategoryMinibar, UnitPrice: 12, PostedBy: 3}
+	if err := service.By(7).PostCharge(booking.ID, charge); err != nil {
+		t.Fatal(err)
+	}
+
+	if charge.PostedBy != 7 {
+		t.Errorf("charge posted by %d, want 7", charge.PostedBy)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	customerID := uint(1)
+
+	tests := []struct {
+		name                     string
+		bookingID, roomBookingID uint
+		first, nights            int
+		code                     string
+		amount                   float64
+	}{
+		{"onto one of its own nights", 1, 1, 3, 2, "", 200},
+		{"into another booking's night", 1, 1, 5, 2, apierror.CodeConflict, 0},
+		{"into a maintenance block", 1, 1, 8, 2, apierror.CodeConflict, 0},
+		{"a room booking of another booking", 2, 1, 3, 2, apierror.CodeNotFound, 0},
+		{"a stay that is over", 3, 3, 4, 1, apierror.CodeConflict, 0},
+		{"the first night of a checked in stay", 4, 4, 2, 2, apierror.CodeConflict, 0},
+		{"more nights for a checked in guest", 4, 4, 1, 3, "", 600},
+		{"no nights", 1, 1, 3, 0, apierror.CodeValidation, 0},
+	}
+
+	for _, test := range tests {
+		service, db := newService(t,
+			newBooking("cash", newStay(1, 2, 2, 100, StayStatusReserved)),
+			newBooking("cash", newStay(1, 6, 1, 100, StayStatusReserved)),
+			newBooking("cash", newStay(2, 4, 1, 200, StayStatusCheckedOut)),
+			newBooking("cash", newStay(2, 1, 2, 200, StayStatusCheckedIn)),
+			&MaintenanceBlock{RoomID: 1, StartDate: dateOnly(day(9)), EndDate: dateOnly(day(9)), Reason: "leak"},
+		)
+
+		before, err := NewStore(db).Bookings().FindRoomBooking(test.roomBookingID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := &UpdateBookingRequest{
+			CustomerID:     &customerID,
+			PaymentMethod:  "transfer",
+			NumberOfNights: uint(test.nights),
+			StartDate:      dateOnly(day(test.first)),
+		}
+
+		err = service.Update(test.bookingID, test.roomBookingID, request)
+		if got := errorCode(t, err); got != test.code {
+			t.Errorf("%s: error %q, want %q (%v)", test.name, got, test.code, err)
+			continue
+		}
+
+		after, err := NewStore(db).Bookings().FindFolio(before.BookingID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stay := after.RoomBookings[0]
+
+		if test.code != "" {
+			if !stay.StartDate.Equal(before.StartDate) || stay.NumberOfNights != before.NumberOfNights || after.PaymentMethod != "cash" {
+				t.Errorf("%s: refused but the booking changed", test.name)
+			}
+
+			continue
+		}
+
+		if !stay.StartDate.Equal(day(test.first)) || stay.NumberOfNights != uint(test.nights) || stay.RoomID != before.RoomID {
+			t.Errorf("%s: room %d for %d nights from %v, want room %d for %d from %v", test.name, stay.RoomID, stay.NumberOfNights, stay.StartDate, before.RoomID, test.nights, day(test.first))
+		}
+
+		if *after.Amount != test.amount || after.PaymentMethod != "transfer" {
+			t.Errorf("%s: %v paid by %s, want %v by transfer", test.name, *after.Amount, after.PaymentMethod, test.amount)
+		}
+	}
+}
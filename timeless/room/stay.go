@@ -0,0 +1,95 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+)
+
+// the state of a room booking's stay. a stay is reserved until the guest is checked in, then checked in until they
+// are checked out. a guest that never arrives is a no show, a stay of a cancelled booking is cancelled
+const (
+	StayStatusReserved   = "reserved"
+	StayStatusCheckedIn  = "checked_in"
+	StayStatusCheckedOut = "checked_out"
+	StayStatusNoShow     = "no_show"
+	StayStatusCancelled  = "cancelled"
+)
+
+var StayStatuses = []string{
+	StayStatusReserved,
+	StayStatusCheckedIn,
+	StayStatusCheckedOut,
+	StayStatusNoShow,
+	StayStatusCancelled,
+}
+
+// stayTransitions the statuses a stay can move to from each status, the others are final
+var stayTransitions = map[string][]string{
+	StayStatusReserved:  {StayStatusCheckedIn, StayStatusNoShow, StayStatusCancelled},
+	StayStatusCheckedIn: {StayStatusCheckedOut},
+}
+
+// holdsRoom check if the stay still holds its room, i.e. it is reserved or the guest is in
+func (rb RoomBookings) holdsRoom() bool {
+	return rb.Status == StayStatusReserved || rb.Status == StayStatusCheckedIn
+}
+
+// canMoveTo refuse moving the stay to status when stayTransitions doesn't allow it
+func (rb RoomBookings) canMoveTo(status string) error {
+	if !slices.Contains(stayTransitions[rb.Status], status) {
+		return apierror.Conflict(fmt.Sprintf("room booking %d can't go from %s to %s", rb.ID, rb.Status, status))
+	}
+
+	return nil
+}
+
+// firstNight the night the stay starts on
+func (rb RoomBookings) firstNight() time.Time {
+	return dateOnly(rb.StartDate)
+}
+
+// lastNight the night the stay ends after
+func (rb RoomBookings) lastNight() time.Time {
+	return rb.firstNight().AddDate(0, 0, int(rb.NumberOfNights)-1)
+}
+
+// NoShow mark a reserved stay whose guest never arrived as a no show, the room is free for the rest of its nights.
+// the nights stay on the folio, cancel or update the booking to change what is owed
+func (s *BookingService) NoShow(roomBookingID uint) (RoomBookings, error) {
+	var roomBooking RoomBookings
+
+	err := s.store.Transaction(func(tx Store) error {
+		before, err := tx.Bookings().FindRoomBooking(roomBookingID)
+		if err != nil {
+			return err
+		}
+
+		if before.ID == 0 {
+			return apierror.NotFound("room booking")
+		}
+
+		if err = before.canMoveTo(StayStatusNoShow); err != nil {
+			return err
+		}
+
+		if dateOnly(s.Now()).Before(before.firstNight()) {
+			return apierror.Conflict("a guest can't be a no show before the first night of their stay")
+		}
+
+		if err = tx.Bookings().UpdateRoomBooking(before.ID, map[string]any{"Status": StayStatusNoShow}); err != nil {
+			return err
+		}
+
+		if roomBooking, err = tx.Bookings().FindRoomBooking(before.ID); err != nil {
+			return err
+		}
+
+		return s.record(tx, audit.ActionNoShow, audit.EntityRoomBooking, roomBooking.ID, before, roomBooking)
+	})
+
+	return roomBooking, err
+}
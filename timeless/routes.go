@@ -1,92 +1,162 @@
 package main
 
 import (
-	"net/http"
+	"errors"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
 	"github.com/hidenkeys/timeless/customer"
+	"github.com/hidenkeys/timeless/invoice"
 	"github.com/hidenkeys/timeless/jwtware"
+	"github.com/hidenkeys/timeless/rbac"
+	"github.com/hidenkeys/timeless/report"
 	"github.com/hidenkeys/timeless/room"
 	"github.com/hidenkeys/timeless/user"
 )
 
-func requireAuth() fiber.Handler {
+// handlers everything the routes are served by, built once by main
+type handlers struct {
+	auth      *user.AuthService
+	rooms     *room.Handler
+	users     *user.Handler
+	customers *customer.Handler
+	invoices  *invoice.Handler
+	reports   *report.Handler
+	audit     *audit.Handler
+}
+
+// requireAuth let through requests with a valid access token of an open session. a bad, expired or revoked token
+// is a 401, failing to look the session up is an internal error as the token may well be fine
+func (h *handlers) requireAuth() fiber.Handler {
 	return jwtware.New(jwtware.Config{
-		SigningKey: jwtware.SigningKey{Key: []byte("secret"), JWTAlg: jwt.SigningMethodHS256.Alg()},
+		SigningKey: jwtware.SigningKey{Key: h.auth.SigningKey, JWTAlg: jwt.SigningMethodHS256.Alg()},
+		IsRevoked: func(c fiber.Ctx, token *jwt.Token) (bool, error) {
+			revoked, err := h.auth.IsRevoked(c, token)
+			if err != nil {
+				return false, apierror.Internal(err)
+			}
+			return revoked, nil
+		},
+		ErrorHandler: func(c fiber.Ctx, err error) error {
+			var apiErr *apierror.Error
+			if errors.As(err, &apiErr) {
+				return apiErr
+			}
+			if errors.Is(err, jwtware.ErrJWTMissingOrMalformed) {
+				return apierror.Unauthorized("missing or malformed JWT")
+			}
+			return apierror.Unauthorized("invalid or expired JWT")
+		},
 	})
 }
 
-func adminOnly(c fiber.Ctx) error {
-	userToken := c.Locals("user").(*jwt.Token)
-	claims := userToken.Claims.(jwt.MapClaims)
-	isAdmin := claims["is_admin"].(bool)
-
-	if !isAdmin {
-		return c.Status(http.StatusForbidden).SendString("only admins are permitted")
-	}
-
-	return c.Next()
-}
+// fiber runs the middleware given after a route's handler before the handler itself,
+// so every route lists its handler first and then the permissions it requires
 
 // isPaid, customer, employee
-func bookingRoutes(r fiber.Router) {
-	//r.Use(requireAuth())
-	r.Get("/:id", room.GetBookingById)
-	r.Patch("/pay", room.ChangePaymentStatus)
-	r.Get("/search/getSummary", room.GetBookingSummary)
-	r.Post("", room.BookRoom)
-	r.Patch("/booking/:bookingId/roomBooking/:roomBookingId", room.UpdateBooking)
-	r.Patch("/checkin/:id", room.CheckIn)
-	r.Patch("/checkout/:id", room.CheckOut)
-	r.Get("/booking/:bookingId/roomBooking/:roomBookingId", room.ViewSingleRoomBooking)
-	// extend-stay// get booking by customers
+func (h *handlers) bookingRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("/:id", h.rooms.GetBookingById, rbac.Require(rbac.BookingRead))
+	r.Patch("/:id/pay", h.rooms.ChangePaymentStatus, rbac.Require(rbac.PaymentRecord))
+	r.Post("/:id/payments", h.rooms.AddPayment, rbac.Require(rbac.PaymentRecord))
+	r.Get("/:id/payments", h.rooms.GetPayments, rbac.Require(rbac.BookingRead))
+	r.Get("/:id/folio", h.rooms.GetFolio, rbac.Require(rbac.BookingRead))
+	r.Post("/:id/charges", h.rooms.PostCharge, rbac.Require(rbac.FolioPost))
+	r.Delete("/:id/charges/:chargeId", h.rooms.VoidCharge, rbac.Require(rbac.FolioVoid))
+	r.Get("/:id/invoice", h.invoices.GetInvoice, rbac.Require(rbac.BookingRead))
+	r.Get("/search/getSummary", h.rooms.GetBookingSummary, rbac.Require(rbac.ReportView))
+	r.Post("", h.rooms.BookRoom, rbac.Require(rbac.BookingCreate))
+	r.Patch("/booking/:bookingId/roomBooking/:roomBookingId", h.rooms.UpdateBooking, rbac.Require(rbac.BookingUpdate))
+	r.Patch("/checkin/:id", h.rooms.CheckIn, rbac.Require(rbac.BookingCheckIn))
+	r.Patch("/checkout/:id", h.rooms.CheckOut, rbac.Require(rbac.BookingCheckIn))
+	r.Patch("/noshow/:id", h.rooms.NoShow, rbac.Require(rbac.BookingCheckIn))
+	r.Get("/booking/:bookingId/roomBooking/:roomBookingId", h.rooms.ViewSingleRoomBooking, rbac.Require(rbac.BookingRead))
+	r.Patch("/roomBooking/:id/extend", h.rooms.ExtendStay, rbac.Require(rbac.BookingUpdate))
+	r.Patch("/roomBooking/:id/move", h.rooms.MoveRoom, rbac.Require(rbac.BookingUpdate))
+	r.Patch("/:id/cancel", h.rooms.CancelBooking, rbac.Require(rbac.BookingCancel))
+	// get booking by customers
 	// export summary
 
-	//r.Use(adminOnly)
-	r.Get("", room.GetAllBookings)
-	r.Delete("/:id", room.DeleteBooking)
+	r.Get("", h.rooms.GetAllBookings, rbac.Require(rbac.BookingRead))
+	r.Delete("/:id", h.rooms.DeleteBooking, rbac.Require(rbac.BookingDelete))
 }
 
-func userRoutes(r fiber.Router) {
+func (h *handlers) userRoutes(r fiber.Router) {
 	// login in
-	r.Post("/auth/login", user.Login)
-	r.Post("/auth/logout", user.Logout)
-	r.Post("", user.CreateEmployee)
-	// crud employees --> only admin
-	//r.Use(requireAuth())
-	r.Get("", user.SearchEmployee) // optional_parameter [role, name, job_role]
-	r.Patch("/:id/changePassword", user.ChangePassword)
-
-	//r.Use(adminOnly)
-	r.Get("/summary", user.GeneralSummary)
-	r.Patch("/:id", user.UpdateEmployee)
-	r.Get("/get-all", user.GetAllUsers)
-	r.Get("/:id", user.GetById)
-	r.Delete("/:id", user.DeleteEmployee)
+	r.Post("/auth/login", h.users.Login)
+	r.Post("/auth/refresh", h.users.RefreshToken)
+	r.Post("/auth/logout", h.users.Logout, h.requireAuth())
+
+	r.Use(h.requireAuth())
+	r.Post("", h.users.CreateEmployee, rbac.Require(rbac.UserManage))
+	r.Get("", h.users.SearchEmployee, rbac.Require(rbac.UserRead)) // optional_parameter [role, name, job_role]
+	r.Patch("/:id/changePassword", h.users.ChangePassword, rbac.RequireSelfOr("id", rbac.UserManage))
+
+	r.Get("/summary", h.users.GeneralSummary, rbac.Require(rbac.ReportExport))
+	r.Patch("/:id", h.users.UpdateEmployee, rbac.Require(rbac.UserManage))
+	r.Get("/get-all", h.users.GetAllUsers, rbac.Require(rbac.UserRead))
+	r.Get("/:id", h.users.GetById, rbac.RequireSelfOr("id", rbac.UserRead))
+	r.Delete("/:id", h.users.DeleteEmployee, rbac.Require(rbac.UserManage))
+}
+
+func (h *handlers) roomRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("", h.rooms.SearchWithFilter, rbac.Require(rbac.RoomRead))
+	r.Get("/available", h.rooms.GetAvailableRooms, rbac.Require(rbac.RoomRead))
+	r.Get("/categories", h.rooms.GetAllCategories, rbac.Require(rbac.RoomRead))
+	r.Get("/:id", h.rooms.GetById, rbac.Require(rbac.RoomRead))
+	r.Get("/:id/bookedDates", h.rooms.GetBookedDates, rbac.Require(rbac.RoomRead))
+
+	r.Post("", h.rooms.Create, rbac.Require(rbac.RoomManage))
+	r.Patch("/:id", h.rooms.Update, rbac.Require(rbac.RoomManage))
+	r.Patch("/:id/status", h.rooms.SetRoomStatus, rbac.Require(rbac.HousekeepingWork))
+
+	r.Get("/:id/blocks", h.rooms.GetBlocks, rbac.Require(rbac.RoomRead))
+	r.Post("/:id/blocks", h.rooms.OpenBlock, rbac.Require(rbac.MaintenanceManage))
+	r.Patch("/:id/blocks/:blockId", h.rooms.UpdateBlock, rbac.Require(rbac.MaintenanceManage))
+	r.Patch("/:id/blocks/:blockId/close", h.rooms.CloseBlock, rbac.Require(rbac.MaintenanceManage))
+}
+
+func (h *handlers) customerRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Post("", h.customers.Create, rbac.Require(rbac.CustomerWrite))
+	r.Get("", h.customers.GetAll, rbac.Require(rbac.CustomerRead))
+	r.Get("/:id", h.customers.GetById, rbac.Require(rbac.CustomerRead))
+	r.Get("/search/findByName", h.customers.FindByName, rbac.Require(rbac.CustomerRead))
+	r.Patch("/:id", h.customers.Update, rbac.Require(rbac.CustomerWrite))
+	r.Get("/:id/bookings", h.customers.GetBookings, rbac.Require(rbac.CustomerRead, rbac.BookingRead))
+
+	r.Delete("/:id", h.customers.Delete, rbac.Require(rbac.CustomerDelete))
+}
+
+func (h *handlers) ratePlanRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("", h.rooms.GetAllRatePlans, rbac.Require(rbac.RoomRead))
+	r.Get("/:id", h.rooms.GetRatePlanById, rbac.Require(rbac.RoomRead))
+
+	r.Post("", h.rooms.CreateRatePlan, rbac.Require(rbac.RatePlanManage))
+	r.Patch("/:id", h.rooms.UpdateRatePlan, rbac.Require(rbac.RatePlanManage))
+	r.Delete("/:id", h.rooms.DeleteRatePlan, rbac.Require(rbac.RatePlanManage))
+}
+
+func (h *handlers) reportRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("", h.reports.GetReport, rbac.Require(rbac.ReportView))
 }
 
-func roomRoutes(r fiber.Router) {
-	//r.Use(requireAuth())
-	r.Get("", room.SearchWithFilter)
-	r.Get("/:id", room.GetById)
-	r.Get("/categories", room.GetAllCategories)
-	r.Get("/:id/bookedDates", room.GetBookedDates)
+func (h *handlers) housekeepingRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("/tasks", h.rooms.GetTasks, rbac.Require(rbac.HousekeepingRead)) // optional_parameter [status, type, roomId, assignedTo]
+	r.Get("/tasks/:id", h.rooms.GetTaskById, rbac.Require(rbac.HousekeepingRead))
 
-	//r.Use(adminOnly)
-	r.Post("", adminOnly, room.Create)
-	r.Patch("/:id", adminOnly, room.Update)
+	r.Post("/tasks", h.rooms.CreateTask, rbac.Require(rbac.HousekeepingManage))
+	r.Patch("/tasks/:id/claim", h.rooms.ClaimTask, rbac.Require(rbac.HousekeepingWork))
+	r.Patch("/tasks/:id/complete", h.rooms.CompleteTask, rbac.Require(rbac.HousekeepingWork))
 }
 
-func customerRoutes(r fiber.Router) {
-	//r.Use(requireAuth())
-	r.Post("", customer.Create)
-	r.Get("", customer.GetAll)
-	r.Get("/:id", customer.GetById)
-	r.Get("/search/findByName", customer.FindByName)
-	r.Patch("/:id", customer.Update)
-	r.Get("/:id/bookings", customer.GetBookings)
-
-	//r.Use(adminOnly)
-	r.Delete("/:id", customer.Delete)
+func (h *handlers) auditRoutes(r fiber.Router) {
+	r.Use(h.requireAuth())
+	r.Get("", h.audit.List, rbac.Require(rbac.AuditView)) // optional_parameter [entity, entityId, actor, action, from, to]
 }
@@ -0,0 +1,115 @@
+// Package search finds the rows of a table whose columns contain every word of a query, best match first.
+// On SQLite built with FTS5 (make build, or go build -tags sqlite_fts5) it goes through the <table>_fts index kept in
+// sync by triggers, see migrations.SearchIndexes. Anywhere else it falls back to LIKE and ranks exact and prefix
+// matches higher
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxTerms the words of a query past this are ignored
+const MaxTerms = 8
+
+// Index the columns of a table that can be searched
+type Index struct {
+	table    string
+	columns  []string
+	fullText bool
+}
+
+// NewIndex an Index over columns of table, using full text search when db has the table's FTS5 index
+func NewIndex(db *gorm.DB, table string, columns ...string) Index {
+	index := Index{table: table, columns: columns}
+
+	if db.Dialector.Name() == "sqlite" {
+		var count int64
+		db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", index.fts()).Scan(&count)
+		index.fullText = count > 0
+	}
+
+	return index
+}
+
+func (i Index) fts() string {
+	return i.table + "_fts"
+}
+
+// FullText whether searches go through the FTS5 index
+func (i Index) FullText() bool {
+	return i.fullText
+}
+
+// Terms the lower cased words of a query, quotes are dropped as they would change the meaning of a full text query
+func Terms(q string) []string {
+	terms := strings.Fields(strings.ToLower(strings.ReplaceAll(q, `"`, " ")))
+	if len(terms) > MaxTerms {
+		terms = terms[:MaxTerms]
+	}
+
+	return terms
+}
+
+// Match narrow query to the rows with every term in one of the columns. rank orders the matches best first and
+// ties by id, it replaces any order the query already has so add it with Clauses last, after counting
+func (i Index) Match(query *gorm.DB, terms []string) (matches *gorm.DB, rank clause.OrderBy) {
+	if i.fullText {
+		return i.matchFullText(query, terms)
+	}
+
+	return i.matchLike(query, terms)
+}
+
+func (i Index) matchFullText(query *gorm.DB, terms []string) (*gorm.DB, clause.OrderBy) {
+	// every term is a prefix, so "jo" finds john, and a row has to have all of them
+	phrases := make([]string, len(terms))
+	for n, term := range terms {
+		phrases[n] = `"` + term + `"*`
+	}
+
+	query = query.
+		Joins(fmt.Sprintf("JOIN %[1]s ON %[1]s.rowid = %[2]s.id", i.fts(), i.table)).
+		Where(fmt.Sprintf("%s MATCH ?", i.fts()), strings.Join(phrases, " "))
+
+	// bm25 is lower for better matches
+	rank := clause.OrderBy{Expression: clause.Expr{SQL: fmt.Sprintf("bm25(%s), %s.id", i.fts(), i.table)}}
+	return query, rank
+}
+
+func (i Index) matchLike(query *gorm.DB, terms []string) (*gorm.DB, clause.OrderBy) {
+	var score []string
+	var scoreVars []any
+
+	for _, term := range terms {
+		contains := make([]string, len(i.columns))
+		containsVars := make([]any, len(i.columns))
+		equals := make([]string, len(i.columns))
+		startsWith := make([]string, len(i.columns))
+
+		for n, column := range i.columns {
+			contains[n] = fmt.Sprintf("lower(%s.%s) LIKE ?", i.table, column)
+			containsVars[n] = "%" + term + "%"
+			equals[n] = fmt.Sprintf("lower(%s.%s) = ?", i.table, column)
+			startsWith[n] = fmt.Sprintf("lower(%s.%s) LIKE ?", i.table, column)
+		}
+
+		query = query.Where("("+strings.Join(contains, " OR ")+")", containsVars...)
+
+		// a term equal to a column counts most, then one a column starts with
+		score = append(score, fmt.Sprintf("CASE WHEN %s THEN 2 WHEN %s THEN 1 ELSE 0 END",
+			strings.Join(equals, " OR "), strings.Join(startsWith, " OR ")))
+		for range i.columns {
+			scoreVars = append(scoreVars, term)
+		}
+		for range i.columns {
+			scoreVars = append(scoreVars, term+"%")
+		}
+	}
+
+	rank := clause.OrderBy{Expression: clause.Expr{SQL: fmt.Sprintf("(%s) DESC, %s.id", strings.Join(score, " + "), i.table), Vars: scoreVars}}
+	return query, rank
+}
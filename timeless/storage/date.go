@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Date a DATE value read from a query. sqlite returns dates as text while postgres
+// and mysql return time.Time, Date accepts either
+type Date struct {
+	time.Time
+}
+
+func (d *Date) Scan(value any) error {
+	switch v := value.(type) {
+	case time.Time:
+		d.Time = v
+		return nil
+	case []byte:
+		return d.parse(string(v))
+	case string:
+		return d.parse(v)
+	case nil:
+		d.Time = time.Time{}
+		return nil
+	}
+
+	return fmt.Errorf("can't scan %T into a date", value)
+}
+
+func (d *Date) parse(s string) error {
+	if len(s) < len(time.DateOnly) {
+		return fmt.Errorf("invalid date %q", s)
+	}
+
+	t, err := time.Parse(time.DateOnly, s[:len(time.DateOnly)])
+	if err != nil {
+		return err
+	}
+
+	d.Time = t
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.Format(time.DateOnly), nil
+}
+
+// String the date as yyyy-mm-dd
+func (d Date) String() string {
+	return d.Format(time.DateOnly)
+}
@@ -2,23 +2,71 @@ package storage
 
 import (
 	"fmt"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
+// the database drivers ConnectDB can open
+const (
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+// ConnectDB open the database. For sqlite, transactions take the write lock as
+// soon as they begin (_txlock=immediate) so a read-check-insert inside one can't
+// interleave with another, and waiting writers retry for up to 5s. Postgres and
+// mysql get the same guarantee from the row locks taken with SELECT ... FOR UPDATE
+func ConnectDB(driver, dsn string) (*gorm.DB, error) {
+	dialector, err := Dialector(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
 
-func ConnectDB() (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open("./timeless.db"), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// turn the drivers' duplicate key and foreign key errors into gorm.ErrDuplicatedKey and gorm.ErrForeignKeyViolated
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("connected to db")
-
-	DB = db
 	return db, nil
 }
+
+// SQLiteDSN add the transaction locking and busy timeout ConnectDB describes to a sqlite dsn
+func SQLiteDSN(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+
+	return dsn + separator + "_txlock=immediate&_busy_timeout=5000"
+}
+
+// Dialector the gorm dialector for a driver and dsn, set up as ConnectDB describes
+func Dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case SQLite:
+		return sqlite.Open(SQLiteDSN(dsn)), nil
+	case Postgres:
+		return postgres.Open(dsn), nil
+	case MySQL:
+		// dates come back as time.Time like they do on the other databases
+		cfg, err := gomysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ParseTime = true
+		return mysql.Open(cfg.FormatDSN()), nil
+	}
+
+	return nil, fmt.Errorf("unsupported database driver %q", driver)
+}
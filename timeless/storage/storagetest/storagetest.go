@@ -0,0 +1,166 @@
+// Package storagetest gives tests a database of their own to run the repositories and services against
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hidenkeys/timeless/migrations"
+	"github.com/hidenkeys/timeless/storage"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// New open an empty in-memory sqlite database with every migration applied, it is closed when the test ends.
+// every call gets a database of its own so tests don't see each other's rows
+func New(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	db := open(tb, sqlite.Open("file::memory:"))
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		tb.Fatalf("storagetest: open: %v", err)
+	}
+
+	// an in-memory database only lives as long as its connection, a second connection would open another one
+	sqlDB.SetMaxOpenConns(1)
+
+	return migrate(tb, db)
+}
+
+// NewFile open an empty sqlite database file in the test's temporary directory with every migration applied.
+// it is opened the way storage.ConnectDB opens sqlite, with several connections and immediate transactions,
+// for tests of what happens when requests run at the same time
+func NewFile(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "timeless.db")
+	return migrate(tb, open(tb, sqlite.Open(storage.SQLiteDSN(path))))
+}
+
+// ForEachDialect run fn as a subtest against an empty database of every driver: sqlite, postgres and mysql. postgres
+// and mysql are the databases named by TIMELESS_TEST_POSTGRES_DSN and TIMELESS_TEST_MYSQL_DSN, everything in those is
+// dropped so point them at databases kept for the tests (make test-dialects starts some in docker). without them the
+// tests start servers of their own, mysql in the test binary and postgres from binaries downloaded the first time.
+// packages using it call Main from their TestMain to stop the servers
+func ForEachDialect(t *testing.T, fn func(t *testing.T, db *gorm.DB)) {
+	forEachDialect(t, false, fn)
+}
+
+// ForEachLockingDialect ForEachDialect for tests of row locks and transactions running at the same time. the mysql
+// the tests start has neither, against it the subtest is skipped unless TIMELESS_TEST_MYSQL_DSN names a real server
+func ForEachLockingDialect(t *testing.T, fn func(t *testing.T, db *gorm.DB)) {
+	forEachDialect(t, true, fn)
+}
+
+func forEachDialect(t *testing.T, locking bool, fn func(t *testing.T, db *gorm.DB)) {
+	t.Run(storage.SQLite, func(t *testing.T) {
+		fn(t, NewFile(t))
+	})
+
+	for _, d := range dialects {
+		t.Run(d.driver, func(t *testing.T) {
+			dsn, kept := os.LookupEnv(d.env)
+			if !kept {
+				if locking && !d.locks {
+					t.Skipf("the %s the tests start doesn't lock rows, set %s or run make test-dialects", d.driver, d.env)
+				}
+
+				var err error
+				if dsn, err = d.newDatabase(); err != nil {
+					var unavailable unavailableError
+					if errors.As(err, &unavailable) {
+						t.Skipf("storagetest: %v, set %s or run make test-dialects", err, d.env)
+					}
+					t.Fatalf("storagetest: %v, set %s to run against a server of your own", err, d.env)
+				}
+			}
+
+			dialector, err := storage.Dialector(d.driver, dsn)
+			if err != nil {
+				t.Fatalf("storagetest: %v", err)
+			}
+
+			db := open(t, dialector)
+
+			// start from nothing, whatever an earlier run left in a kept database is rolled back
+			if kept {
+				if _, err = migrations.Down(db, len(migrations.All())); err != nil {
+					t.Fatalf("storagetest: reset: %v", err)
+				}
+			}
+
+			fn(t, migrate(t, db))
+		})
+	}
+}
+
+// open connect to the database, it is closed when the test ends
+func open(tb testing.TB, dialector gorm.Dialector) *gorm.DB {
+	tb.Helper()
+
+	db, err := openQuiet(dialector)
+	if err != nil {
+		tb.Fatalf("storagetest: open: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		tb.Fatalf("storagetest: open: %v", err)
+	}
+
+	tb.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// openQuiet connect to the database without logging the queries
+func openQuiet(dialector gorm.Dialector) (*gorm.DB, error) {
+	return gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
+	})
+}
+
+func migrate(tb testing.TB, db *gorm.DB) *gorm.DB {
+	tb.Helper()
+
+	if _, err := migrations.Up(db); err != nil {
+		tb.Fatalf("storagetest: migrate: %v", err)
+	}
+
+	return db
+}
+
+// Create insert every value in order, failing the test when one can't be saved
+func Create(tb testing.TB, db *gorm.DB, values ...any) {
+	tb.Helper()
+
+	for _, value := range values {
+		if result := db.Create(value); result.Error != nil {
+			tb.Fatalf("storagetest: create %T: %v", value, result.Error)
+		}
+	}
+}
+
+// User a member of staff for fixtures that reference users, like the receptionist of a booking. postgres and mysql
+// hold bookings to the users they name. it stands in for user.User, whose package imports the ones that need it
+type User struct {
+	gorm.Model
+	Email *string `gorm:"unique;size:255"`
+	Role  string
+}
+
+func (User) TableName() string { return "users" }
+
+// NewUser a receptionist with the given id
+func NewUser(id uint) *User {
+	email := fmt.Sprintf("staff%d@timeless.test", id)
+	user := &User{Email: &email, Role: "receptionist"}
+	user.ID = id
+	return user
+}
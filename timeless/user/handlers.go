@@ -2,17 +2,19 @@ package user
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"fmt"
 	"github.com/gofiber/fiber/v3"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/hidenkeys/timeless/storage"
+	"github.com/hidenkeys/timeless/apierror"
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/rbac"
+	"github.com/hidenkeys/timeless/validation"
 	"github.com/xuri/excelize/v2"
 	"golang.org/x/crypto/bcrypt"
 	"math/big"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 )
 
 const (
@@ -21,6 +23,9 @@ const (
 	prefix  = "TO-"
 )
 
+// errIncorrectLogin the same for an unknown login and a wrong password so neither can be told apart
+var errIncorrectLogin = apierror.Unauthorized("incorrect email or password")
+
 // generateRandomString generates a random string of fixed length
 func generateRandomString(length int, charset string) (string, error) {
 	result := make([]byte, length)
@@ -43,59 +48,56 @@ func generateCustomString() (string, error) {
 	return prefix + randomPart, nil
 }
 
-func Login(c fiber.Ctx) error {
+// Handler the user and sign in endpoints
+type Handler struct {
+	users Repository
+	auth  *AuthService
+}
+
+func NewHandler(users Repository, auth *AuthService) *Handler {
+	return &Handler{users: users, auth: auth}
+}
+
+func (h *Handler) Login(c fiber.Ctx) error {
 	loginRequest := make(map[string]string)
 	if err := c.Bind().JSON(&loginRequest); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
 	}
 
-	var user User
-	if result := storage.DB.Raw("SELECT * FROM users WHERE email == @username OR employeeId == @username LIMIT 1", sql.Named("username", loginRequest["username"])).Find(&user); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON("incorect email or password")
+	user, err := h.users.FindByLogin(loginRequest["username"])
+	if err != nil {
+		return err
 	}
 
 	if user.ID == 0 {
-		return c.Status(http.StatusBadRequest).JSON("incorect email or password")
+		return errIncorrectLogin
 	}
 
 	// valid password
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest["password"]))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest["password"]))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON("incorect email or password")
-	}
-	claims := jwt.MapClaims{
-		"user_id":  user.ID,
-		"is_admin": user.IsAdmin,
-		"role":     user.Role,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
-		"iat":      time.Now().Unix(),
+		return errIncorrectLogin
 	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Generate encoded token and send it as response.
-	t, err := token.SignedString([]byte("secret"))
+	response, err := h.auth.StartSession(user, c.Get(fiber.HeaderUserAgent), c.IP())
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(err.Error())
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"token": t,
-		"user":  user,
-	})
+	return c.Status(http.StatusOK).JSON(response)
 }
 
-func Logout(c fiber.Ctx) error {
-	// Clear the auth token cookie by setting an expired cookie
-	cookie := fiber.Cookie{
-		Name:     "authtoken",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour), // Expire the cookie immediately
-		HTTPOnly: true,
+// Logout revoke the session the access token belongs to, or every session of the user {params: [all]}
+func (h *Handler) Logout(c fiber.Ctx) error {
+	if c.Query("all") == "true" {
+		if err := h.users.RevokeSessions(rbac.UserID(c)); err != nil {
+			return err
+		}
+	} else {
+		if err := h.users.RevokeSession(sessionID(c)); err != nil {
+			return err
+		}
 	}
 
-	c.Cookie(&cookie)
-
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "Successfully logged out",
 	})
@@ -106,25 +108,30 @@ func Signup(c fiber.Ctx) error {
 }
 
 type NewEmployee struct {
-	Email            *string `json:"email"`
+	Email            *string `json:"email" validate:"required,email"`
 	Password         string  `json:"password"`
 	EmployeeID       *string `json:"employeeID"`
-	FirstName        *string `json:"firstName"`
-	LastName         *string `json:"lastName"`
-	Phone            *string `json:"phone"`
+	FirstName        *string `json:"firstName" validate:"required"`
+	LastName         *string `json:"lastName" validate:"required"`
+	Phone            *string `json:"phone" validate:"required,phone"`
 	EmergencyContact *string `json:"emergencyContact"`
 	IsAdmin          bool    `json:"isAdmin"`
 	Role             string  `json:"role"`
 	Salary           float64 `json:"salary"`
 }
 
-func CreateEmployee(c fiber.Ctx) error {
+func (h *Handler) CreateEmployee(c fiber.Ctx) error {
 	newUser := new(NewEmployee)
 
 	if err := c.Bind().JSON(newUser); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
 	}
 
+	if !rbac.ValidRole(newUser.Role) {
+		return apierror.Field("role", "role must be one of "+strings.Join(rbac.Roles, ", "))
+	}
+	newUser.Role = strings.ToLower(newUser.Role)
+
 	result, _ := generateCustomString()
 	newUser.EmployeeID = &result
 
@@ -141,118 +148,204 @@ func CreateEmployee(c fiber.Ctx) error {
 		Salary:           newUser.Salary,
 	}
 
-	if result := storage.DB.Create(newDBUser); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err := h.users.Transaction(func(tx Repository) error {
+		if err := tx.Create(newDBUser); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionCreate, audit.EntityUser, newDBUser.ID, nil, newDBUser)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.Status(http.StatusCreated).JSON(newUser)
 }
 
-func UpdateEmployee(c fiber.Ctx) error {
-	newUserInfo := new(User)
+// employeeFields the fields of an employee UpdateEmployee can change, the password has its own endpoint
+var employeeFields = []string{"email", "firstName", "lastName", "phone", "emergencyContact", "isAdmin", "role", "salary"}
+
+func (h *Handler) UpdateEmployee(c fiber.Ctx) error {
+	newUserInfo := &validation.Partial{Value: new(User)}
 	userId, err := strconv.Atoi(c.Params("id"))
 
 	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fmt.Errorf("invalid user id"))
+		return apierror.InvalidID("user")
 	}
 
-	if err = c.Bind().JSON(&newUserInfo); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+	if err = c.Bind().JSON(newUserInfo); err != nil {
+		return apierror.InvalidBody(err)
 	}
 
-	user := new(User)
-	user.ID = uint(userId)
+	fields, err := newUserInfo.Fields(employeeFields...)
+	if err != nil {
+		return err
+	}
 
-	if result := storage.DB.Model(user).Updates(newUserInfo); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	if role, sent := fields["Role"].(string); sent {
+		if !rbac.ValidRole(role) {
+			return apierror.Field("role", "role must be one of "+strings.Join(rbac.Roles, ", "))
+		}
+		fields["Role"] = strings.ToLower(role)
 	}
 
-	return c.Status(http.StatusOK).JSON(user)
+	var after User
+	err = h.users.Transaction(func(tx Repository) error {
+		before, err := tx.Find(uint(userId))
+		if err != nil {
+			return err
+		}
+
+		if before.ID == 0 {
+			return apierror.NotFound("user")
+		}
+
+		if err = tx.Update(before.ID, fields); err != nil {
+			return err
+		}
+
+		if after, err = tx.Find(before.ID); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionUpdate, audit.EntityUser, before.ID, before, after)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(after)
 }
 
-func DeleteEmployee(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) DeleteEmployee(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	if err != nil {
+		return apierror.InvalidID("user")
 	}
 
-	if result := storage.DB.Exec("DELETE FROM users WHERE id == ?", id); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err = h.users.Transaction(func(tx Repository) error {
+		user, err := tx.Find(uint(id))
+		if err != nil {
+			return err
+		}
+
+		if user.ID == 0 {
+			return apierror.NotFound("user")
+		}
+
+		if err = tx.Delete(user.ID); err != nil {
+			return err
+		}
+
+		if err = tx.RevokeSessions(user.ID); err != nil {
+			return err
+		}
+
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionDelete, audit.EntityUser, user.ID, user, nil)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(http.StatusNoContent)
 }
 
-func SearchEmployee(c fiber.Ctx) error {
-	//limit := c.Query("page_size", "10")
-	//offset := c.Query("page", "0")
-	name := c.Query("name")
-	wildcard := "%" + name + "%"
-
-	var users []User
+// SearchEmployee {params: [name, page, page_size, sort, order]}
+// name can be several words e.g. "john doe", the best matches come first unless sort is given
+func (h *Handler) SearchEmployee(c fiber.Ctx) error {
+	page, err := pagination.FromQuery(c, Sorts, "", "asc")
+	if err != nil {
+		return err
+	}
 
-	if result := storage.DB.Raw("SELECT * FROM users where users.firstName like @name or users.lastName like @name or role like @name or email like @name or phone like @name", sql.Named("name", wildcard)).Find(&users); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	users, total, err := h.users.Search(c.Query("name"), page)
+	if err != nil {
+		return err
 	}
-	return c.Status(http.StatusOK).JSON(users)
+	return c.Status(http.StatusOK).JSON(pagination.New(users, total, page))
 }
 
-func GetAllUsers(c fiber.Ctx) error {
-	//limit := c.Query("page_size", "10")
-	//offset := c.Query("page", "0")
-	var users []User
-	if result := storage.DB.Raw("SELECT * FROM users").Find(&users); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+// GetAllUsers {params: [page, page_size, sort, order]}
+func (h *Handler) GetAllUsers(c fiber.Ctx) error {
+	page, err := pagination.FromQuery(c, Sorts, "id", "asc")
+	if err != nil {
+		return err
+	}
+
+	users, total, err := h.users.List(page)
+	if err != nil {
+		return err
 	}
 
-	return c.Status(http.StatusOK).JSON(users)
+	return c.Status(http.StatusOK).JSON(pagination.New(users, total, page))
 }
 
-func GetById(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) GetById(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid user id")
+	if err != nil {
+		return apierror.InvalidID("user")
 	}
 
-	var user User
-
-	if result := storage.DB.Raw("SELECT * FROM users WHERE id == ?", id).Scan(&user); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	user, err := h.users.Find(uint(id))
+	if err != nil {
+		return err
 	}
 
 	if user.ID == 0 {
-		return c.Status(http.StatusBadRequest).SendString("invalid user id")
+		return apierror.NotFound("user")
 	}
 
 	return c.Status(http.StatusOK).JSON(user)
 }
 
-func ChangePassword(c fiber.Ctx) error {
-	id := c.Params("id")
+func (h *Handler) ChangePassword(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 
-	if id == "" {
-		return c.Status(http.StatusBadRequest).SendString("invalid customer id")
+	if err != nil {
+		return apierror.InvalidID("user")
 	}
 
 	requestBody := make(map[string]string)
 
 	if err := c.Bind().JSON(&requestBody); err != nil {
-		return c.Status(http.StatusBadRequest).JSON(err)
+		return apierror.InvalidBody(err)
 	}
 
 	if requestBody["password"] != requestBody["confirmPassword"] {
-		return c.Status(http.StatusInternalServerError).SendString("passwords don't match")
+		return apierror.Field("confirmPassword", "passwords don't match")
 	}
 
 	hashedPassword, err := generateHashPassword(requestBody["password"])
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(err)
+		return err
 	}
 
-	if result := storage.DB.Exec("UPDATE users SET password = ? WHERE id == ?", hashedPassword, id); result.Error != nil {
-		return c.Status(http.StatusInternalServerError).JSON(result.Error)
+	err = h.users.Transaction(func(tx Repository) error {
+		user, err := tx.Find(uint(id))
+		if err != nil {
+			return err
+		}
+
+		if user.ID == 0 {
+			return apierror.NotFound("user")
+		}
+
+		if err = tx.SetPassword(user.ID, hashedPassword); err != nil {
+			return err
+		}
+
+		// sign the user out everywhere, whoever knew the old password may still hold a session
+		if err = tx.RevokeSessions(user.ID); err != nil {
+			return err
+		}
+
+		// the password itself is never recorded
+		return tx.Audit().Record(rbac.UserID(c), audit.ActionChangePassword, audit.EntityUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		return err
 	}
 
 	return c.SendStatus(http.StatusOK)
@@ -266,27 +359,10 @@ func generateHashPassword(password string) (string, error) {
 	return string(hashedPasswordBytes), nil
 }
 
-func GeneralSummary(c fiber.Ctx) error {
-	start := c.Query("start")
-	end := c.Query("end")
-	var results []struct {
-		FirstName      string  `json:"first_name"`
-		LastName       string  `json:"last_name"`
-		PhoneNumber    string  `json:"phone_number"`
-		Address        string  `json:"address"`
-		EmailAddress   string  `json:"email_address"`
-		PaymentMethod  string  `json:"payment_method"`
-		Amount         float64 `json:"amount"`
-		CheckinDate    string  `json:"checkin_date"`
-		CheckoutDate   string  `json:"checkout_date"`
-		NumberOfNights int     `json:"number_of_nights"`
-		Receptionist   string  `json:"receptionist"`
-		RoomNumber     string  `json:"room_number"`
-	}
-
-	err := storage.DB.Raw("SELECT\n    customers.first_name as FirstName,\n    customers.last_name as LastName,\n    customers.phone as PhoneNumber,\n    customers.address as Address,\n    customers.email as EmailAddress,\n    b.payment_method as PaymentMethod,\n    b.amount as Amount,\n    rb.start_date as CheckinDate,\n    rb.end_date as CheckoutDate,\n    number_of_nights as NumberOfNights,\n    b.receptionist as Receptionist,\n    name as RoomNumber\nFROM customers\njoin bookings b on customers.id = b.customer_id\njoin main.room_bookings rb on b.id = rb.booking_id\njoin main.rooms r on rb.room_id = r.id\nwhere (start_date BETWEEN ? AND ? ) AND (end_date BETWEEN ? AND ?)\n", start, end, start, end).Scan(&results).Error
+func (h *Handler) GeneralSummary(c fiber.Ctx) error {
+	results, err := h.users.GuestStays(c.Query("start"), c.Query("end"))
 	if err != nil {
-		return c.Status(http.StatusInternalServerError).JSON(err.Error())
+		return err
 	}
 
 	f := excelize.NewFile()
@@ -321,9 +397,7 @@ func GeneralSummary(c fiber.Ctx) error {
 
 	filePath := "summary.xlsx"
 	if err := f.SaveAs(filePath); err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate Excel file",
-		})
+		return apierror.Internal(err)
 	}
 
 	return c.Download(filePath)
@@ -7,12 +7,12 @@ import (
 
 type User struct {
 	gorm.Model
-	Email            *string `json:"email" gorm:"unique" validate:"required,email"`
-	Password         string
-	EmployeeID       *string `json:"employeeID" gorm:"unique" validate:"required"`
+	Email            *string `json:"email" gorm:"unique;size:255" validate:"required,email"`
+	Password         string  `json:"-"`
+	EmployeeID       *string `json:"employeeID" gorm:"unique;size:255" validate:"required"`
 	FirstName        *string `json:"firstName" validate:"required"`
 	LastName         *string `json:"lastName" validate:"required"`
-	Phone            *string `json:"phone" validate:"required"`
+	Phone            *string `json:"phone" validate:"required,phone"`
 	EmergencyContact *string `json:"emergencyContact"`
 	IsAdmin          bool    `json:"isAdmin" gorm:"default:0"`
 	Role             string  `json:"role"`
@@ -0,0 +1,226 @@
+package user
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/hidenkeys/timeless/audit"
+	"github.com/hidenkeys/timeless/pagination"
+	"github.com/hidenkeys/timeless/search"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GuestStay a row of the GeneralSummary spreadsheet
+type GuestStay struct {
+	FirstName      string  `json:"first_name"`
+	LastName       string  `json:"last_name"`
+	PhoneNumber    string  `json:"phone_number"`
+	Address        string  `json:"address"`
+	EmailAddress   string  `json:"email_address"`
+	PaymentMethod  string  `json:"payment_method"`
+	Amount         float64 `json:"amount"`
+	CheckinDate    string  `json:"checkin_date"`
+	CheckoutDate   string  `json:"checkout_date"`
+	NumberOfNights int     `json:"number_of_nights"`
+	Receptionist   string  `json:"receptionist"`
+	RoomNumber     string  `json:"room_number"`
+}
+
+// Repository users and their sessions, a user or session that doesn't exist comes back with ID 0.
+// Transaction runs fn with a Repository bound to one database transaction
+type Repository interface {
+	Find(id uint) (User, error)
+	// FindByLogin find a user who isn't deleted by email or employee id
+	FindByLogin(username string) (User, error)
+	// List one page of the users and how many there are in all
+	List(page pagination.Params) ([]User, int64, error)
+	// Search one page of the users with every word of q in their name, email, phone or employee id,
+	// best match first unless the page is sorted
+	Search(q string, page pagination.Params) ([]User, int64, error)
+	Count() (int64, error)
+	Create(user *User) error
+	Update(id uint, fields map[string]any) error
+	SetPassword(id uint, hashedPassword string) error
+	Delete(id uint) error
+	// GuestStays the stays that started and ended between start and end with their guest, room and booking
+	GuestStays(start, end string) ([]GuestStay, error)
+
+	CreateSession(session *Session) error
+	// LockSession find a session and hold a lock on it until the transaction ends
+	LockSession(id uint) (Session, error)
+	// RotateRefreshTokenHash replace the refresh token hash of a session, previousHash is kept to spot a reused token
+	RotateRefreshTokenHash(sessionID uint, previousHash, hash string) error
+	RevokeSession(id uint) error
+	// RevokeSessions sign a user out everywhere
+	RevokeSessions(userID uint) error
+	// SessionOpen check the session is neither revoked nor expired
+	SessionOpen(id uint) (bool, error)
+
+	// Audit the audit log, changes made in a transaction are recorded through the transaction's Repository
+	Audit() audit.Log
+	Transaction(fn func(Repository) error) error
+}
+
+// Sorts what the user lists can be sorted by
+var Sorts = pagination.Sorts{
+	"id":        "users.id",
+	"firstName": "users.first_name",
+	"lastName":  "users.last_name",
+	"email":     "users.email",
+	"role":      "users.role",
+	"createdAt": "users.created_at",
+}
+
+// NewRepository a Repository backed by db
+func NewRepository(db *gorm.DB) Repository {
+	return repository{
+		db:    db,
+		index: search.NewIndex(db, "users", "first_name", "last_name", "email", "phone", "employee_id"),
+	}
+}
+
+type repository struct {
+	db    *gorm.DB
+	index search.Index
+}
+
+func (r repository) Find(id uint) (User, error) {
+	var user User
+	return user, r.db.Raw("SELECT * FROM users WHERE id = ?", id).Scan(&user).Error
+}
+
+func (r repository) FindByLogin(username string) (User, error) {
+	var user User
+	return user, r.db.Raw("SELECT * FROM users WHERE (email = @username OR employee_id = @username) AND deleted_at IS NULL LIMIT 1", sql.Named("username", username)).Find(&user).Error
+}
+
+func (r repository) List(page pagination.Params) ([]User, int64, error) {
+	return r.page(r.db.Model(&User{}), page, nil)
+}
+
+func (r repository) Search(q string, page pagination.Params) ([]User, int64, error) {
+	terms := search.Terms(q)
+	if len(terms) == 0 {
+		return r.List(page)
+	}
+
+	query, rank := r.index.Match(r.db.Model(&User{}), terms)
+	return r.page(query, page, rank)
+}
+
+// page count the users query matches and load one page of them, ordered by rank when the page isn't sorted
+func (r repository) page(query *gorm.DB, page pagination.Params, rank clause.Expression) ([]User, int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if result := query.Count(&total); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	// the rank is the whole order, it ties by id like the page's order does. gorm's Order can't take it
+	if rank != nil && page.Sort == "" {
+		query = query.Clauses(rank).Scopes(page.Window)
+	} else {
+		query = query.Scopes(page.Scope)
+	}
+
+	var users []User
+	if result := query.Find(&users); result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return users, total, nil
+}
+
+func (r repository) Count() (int64, error) {
+	var count int64
+	return count, r.db.Model(&User{}).Count(&count).Error
+}
+
+func (r repository) Create(user *User) error {
+	return r.db.Create(user).Error
+}
+
+func (r repository) Update(id uint, fields map[string]any) error {
+	return r.db.Model(&User{Model: gorm.Model{ID: id}}).Updates(fields).Error
+}
+
+func (r repository) SetPassword(id uint, hashedPassword string) error {
+	return r.db.Exec("UPDATE users SET password = ? WHERE id = ?", hashedPassword, id).Error
+}
+
+func (r repository) Delete(id uint) error {
+	return r.db.Exec("DELETE FROM users WHERE id = ?", id).Error
+}
+
+func (r repository) GuestStays(start, end string) ([]GuestStay, error) {
+	var stays []GuestStay
+
+	err := r.db.Raw(`SELECT
+    customers.first_name as first_name,
+    customers.last_name as last_name,
+    customers.phone as phone_number,
+    customers.address as address,
+    customers.email as email_address,
+    b.payment_method as payment_method,
+    b.amount as amount,
+    rb.start_date as checkin_date,
+    rb.end_date as checkout_date,
+    number_of_nights as number_of_nights,
+    b.receptionist as receptionist,
+    name as room_number
+FROM customers
+join bookings b on customers.id = b.customer_id
+join room_bookings rb on b.id = rb.booking_id
+join rooms r on rb.room_id = r.id
+where (start_date BETWEEN ? AND ? ) AND (end_date BETWEEN ? AND ?)
+`, start, end, start, end).Scan(&stays).Error
+
+	return stays, err
+}
+
+func (r repository) CreateSession(session *Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r repository) LockSession(id uint) (Session, error) {
+	var session Session
+	return session, r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Find(&session).Error
+}
+
+func (r repository) RotateRefreshTokenHash(sessionID uint, previousHash, hash string) error {
+	updates := map[string]any{
+		"PreviousRefreshTokenHash": previousHash,
+		"RefreshTokenHash":         hash,
+	}
+
+	return r.db.Model(&Session{}).Where("id = ?", sessionID).Updates(updates).Error
+}
+
+func (r repository) RevokeSession(id uint) error {
+	return r.db.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now()).Error
+}
+
+func (r repository) RevokeSessions(userID uint) error {
+	return r.db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", time.Now()).Error
+}
+
+func (r repository) SessionOpen(id uint) (bool, error) {
+	var count int64
+	if result := r.db.Model(&Session{}).Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+func (r repository) Audit() audit.Log {
+	return audit.NewLog(r.db)
+}
+
+func (r repository) Transaction(fn func(Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(repository{db: tx, index: r.index})
+	})
+}
@@ -0,0 +1,61 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/hidenkeys/timeless/rbac"
+)
+
+// SeedAdmin create an admin account when there are no users yet, every route except login needs a signed-in user
+// so without it nobody could create the first employee. it gets password, or when that is empty a generated one that
+// is written once to terminal, it is never logged. with neither there is no way to sign in so nothing is created
+func SeedAdmin(users Repository, password string, terminal io.Writer) error {
+	count, err := users.Count()
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	generated := password == ""
+	if generated {
+		if terminal == nil {
+			return errors.New("there are no users yet, set TIMELESS_ADMIN_PASSWORD to create the admin account")
+		}
+
+		if password, err = generateRandomString(16, charset); err != nil {
+			return err
+		}
+	}
+
+	employeeID, err := generateCustomString()
+	if err != nil {
+		return err
+	}
+
+	email := "admin@timeless.local"
+	admin := &User{
+		Email:      &email,
+		Password:   password,
+		EmployeeID: &employeeID,
+		IsAdmin:    true,
+		Role:       rbac.RoleAdmin,
+	}
+
+	if err = users.Create(admin); err != nil {
+		return err
+	}
+
+	log.Printf("created admin user %s", email)
+
+	if generated {
+		_, err = fmt.Fprintf(terminal, "admin password: %s\nit is only shown this once, change it after signing in\n", password)
+	}
+
+	return err
+}
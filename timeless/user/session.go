@@ -0,0 +1,229 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hidenkeys/timeless/apierror"
+	"gorm.io/gorm"
+)
+
+var errInvalidRefreshToken = apierror.Unauthorized("invalid or expired refresh token")
+
+// Session a signed in device, access tokens carry its id as their jti claim and refresh tokens rotate on every use
+type Session struct {
+	gorm.Model
+	UserID           uint       `json:"userId" gorm:"index"`
+	RefreshTokenHash string     `json:"-"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RevokedAt        *time.Time `json:"revokedAt"`
+	UserAgent        string     `json:"userAgent"`
+	IP               string     `json:"ip"`
+	// PreviousRefreshTokenHash the hash of the refresh token swapped last, presenting that token again means it was copied
+	PreviousRefreshTokenHash string `json:"-"`
+}
+
+// newRefreshSecret generate the random half of a refresh token and the hash stored for it
+func newRefreshSecret() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthService issues the tokens of signed in users and keeps track of their sessions
+type AuthService struct {
+	users Repository
+	// SigningKey the HS256 key access tokens are signed with
+	SigningKey []byte
+	// AccessTokenTTL how long an access token is accepted, revoking a session takes effect immediately regardless
+	AccessTokenTTL time.Duration
+	// RefreshTokenTTL how long a session can be refreshed without signing in again
+	RefreshTokenTTL time.Duration
+}
+
+func NewAuthService(users Repository, signingKey []byte, accessTokenTTL, refreshTokenTTL time.Duration) *AuthService {
+	return &AuthService{
+		users:           users,
+		SigningKey:      signingKey,
+		AccessTokenTTL:  accessTokenTTL,
+		RefreshTokenTTL: refreshTokenTTL,
+	}
+}
+
+// signAccessToken sign a short lived access token for a session
+func (a *AuthService) signAccessToken(user User, session Session) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti":      strconv.FormatUint(uint64(session.ID), 10),
+		"user_id":  user.ID,
+		"is_admin": user.IsAdmin,
+		"role":     user.Role,
+		"exp":      now.Add(a.AccessTokenTTL).Unix(),
+		"iat":      now.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(a.SigningKey)
+}
+
+// StartSession open a session for a user who just signed in
+func (a *AuthService) StartSession(user User, userAgent, ip string) (fiber.Map, error) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	session := Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		ExpiresAt:        time.Now().Add(a.RefreshTokenTTL),
+		UserAgent:        userAgent,
+		IP:               ip,
+	}
+
+	if err = a.users.CreateSession(&session); err != nil {
+		return nil, err
+	}
+
+	return a.tokenResponse(user, session, secret)
+}
+
+func (a *AuthService) tokenResponse(user User, session Session, secret string) (fiber.Map, error) {
+	accessToken, err := a.signAccessToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return fiber.Map{
+		"token":        accessToken,
+		"expiresIn":    int(a.AccessTokenTTL.Seconds()),
+		"refreshToken": fmt.Sprintf("%d.%s", session.ID, secret),
+		"user":         user,
+	}, nil
+}
+
+// Refresh swap a refresh token for a new access token and a new refresh token, the old refresh token stops working.
+// presenting the refresh token that was swapped last means it was copied, so the whole session is revoked. any
+// other token that doesn't match is just refused, the session ids are guessable
+func (a *AuthService) Refresh(refreshToken string) (fiber.Map, error) {
+	id, secret, found := strings.Cut(refreshToken, ".")
+	sessionID, err := strconv.ParseUint(id, 10, 0)
+	if !found || secret == "" || err != nil {
+		return nil, errInvalidRefreshToken
+	}
+
+	var response fiber.Map
+	reused := false
+	err = a.users.Transaction(func(tx Repository) error {
+		session, err := tx.LockSession(uint(sessionID))
+		if err != nil {
+			return err
+		}
+
+		if session.ID == 0 || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		hash := hashRefreshSecret(secret)
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(session.RefreshTokenHash)) != 1 {
+			if session.PreviousRefreshTokenHash == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(session.PreviousRefreshTokenHash)) != 1 {
+				return errInvalidRefreshToken
+			}
+
+			// returning an error would roll the revocation back
+			reused = true
+			return tx.RevokeSession(session.ID)
+		}
+
+		user, err := tx.Find(session.UserID)
+		if err != nil {
+			return err
+		}
+
+		if user.ID == 0 {
+			return errInvalidRefreshToken
+		}
+
+		newSecret, newHash, err := newRefreshSecret()
+		if err != nil {
+			return err
+		}
+
+		if err = tx.RotateRefreshTokenHash(session.ID, session.RefreshTokenHash, newHash); err != nil {
+			return err
+		}
+
+		response, err = a.tokenResponse(user, session, newSecret)
+		return err
+	})
+
+	if reused {
+		return nil, errInvalidRefreshToken
+	}
+
+	return response, err
+}
+
+// RefreshToken {body: [refreshToken]}
+func (h *Handler) RefreshToken(c fiber.Ctx) error {
+	requestBody := make(map[string]string)
+	if err := c.Bind().JSON(&requestBody); err != nil {
+		return apierror.InvalidBody(err)
+	}
+
+	response, err := h.auth.Refresh(requestBody["refreshToken"])
+	if err != nil {
+		return err
+	}
+
+	return c.Status(http.StatusOK).JSON(response)
+}
+
+// IsRevoked check for jwtware, an access token is only accepted while the session it was issued for is open
+func (a *AuthService) IsRevoked(c fiber.Ctx, token *jwt.Token) (bool, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return true, nil
+	}
+
+	id, _ := claims["jti"].(string)
+	sessionID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return true, nil
+	}
+
+	open, err := a.users.SessionOpen(uint(sessionID))
+	return !open, err
+}
+
+// sessionID get the id of the session the request's access token belongs to
+func sessionID(c fiber.Ctx) uint {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return 0
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	id, _ := claims["jti"].(string)
+	sessionID, _ := strconv.ParseUint(id, 10, 0)
+	return uint(sessionID)
+}
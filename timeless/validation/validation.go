@@ -0,0 +1,268 @@
+// Package validation checks the validate tags of request bodies when fiber binds them. Besides the
+// go-playground/validator tags it has phone, after=Field and notbefore=Field, and reports what failed
+// as an apierror.Validation keyed by the json path of each field e.g. roomBookings[0].numberOfNights
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hidenkeys/timeless/apierror"
+)
+
+// phonePattern an optional + then 7 to 15 digits, once spaces, dashes, dots and brackets are taken out
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+// Validator the fiber.StructValidator of the app
+type Validator struct {
+	validate *validator.Validate
+}
+
+func New() *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	// name fields by their json name so errors point at what the client sent
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	_ = validate.RegisterValidation("phone", isPhone)
+	_ = validate.RegisterValidation("after", compareTo(func(t, other time.Time) bool { return t.After(other) }))
+	_ = validate.RegisterValidation("notbefore", compareTo(func(t, other time.Time) bool { return !t.Before(other) }))
+
+	return &Validator{validate: validate}
+}
+
+func (v *Validator) Engine() any {
+	return v.validate
+}
+
+// ValidateStruct validate what a body was bound to, anything that isn't a struct (e.g. a map) has nothing to check
+func (v *Validator) ValidateStruct(out any) error {
+	if partial, ok := out.(*Partial); ok {
+		return v.validatePartial(partial)
+	}
+
+	if !isStruct(out) {
+		return nil
+	}
+
+	return translate(v.validate.Struct(out))
+}
+
+// validatePartial only validate the fields the client sent
+func (v *Validator) validatePartial(partial *Partial) error {
+	if !isStruct(partial.Value) {
+		return nil
+	}
+
+	t := reflect.TypeOf(partial.Value)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if _, sent := partial.values[name]; sent && name != "" {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return translate(v.validate.StructPartial(partial.Value, fields...))
+}
+
+// Partial the body of an update, fields the client leaves out keep their current value so only the ones it sent are
+// validated. bind &Partial{Value: x} where x is a pointer to the struct the body is decoded into
+type Partial struct {
+	Value  any
+	values map[string]any
+}
+
+func (p *Partial) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.values); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, p.Value)
+}
+
+// Values the fields the client sent keyed by their json name
+func (p *Partial) Values() map[string]any {
+	return p.values
+}
+
+// Fields the fields the client sent as gorm's Updates takes them, keyed by the name of the struct field with the value
+// it was decoded into. allowed are the json names of the fields that can be changed, sending any other is refused
+func (p *Partial) Fields(allowed ...string) (map[string]any, error) {
+	for name := range p.values {
+		if !slices.Contains(allowed, name) {
+			return nil, apierror.Field(name, fmt.Sprintf("%s can't be changed", name))
+		}
+	}
+
+	v := reflect.ValueOf(p.Value)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	fields := make(map[string]any, len(p.values))
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if _, sent := p.values[name]; sent && name != "" {
+			fields[field.Name] = v.Field(i).Interface()
+		}
+	}
+
+	return fields, nil
+}
+
+func isStruct(out any) bool {
+	v := reflect.ValueOf(out)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+
+	return v.Kind() == reflect.Struct
+}
+
+// translate turn the validator's errors into an apierror.Validation
+func translate(err error) error {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make(map[string]string, len(errs))
+	for _, fieldErr := range errs {
+		// drop the name of the struct that was validated
+		_, path, _ := strings.Cut(fieldErr.Namespace(), ".")
+		if _, ok := fields[path]; !ok {
+			fields[path] = message(fieldErr)
+		}
+	}
+
+	if len(fields) == 1 {
+		for _, msg := range fields {
+			return apierror.Validation(msg, fields)
+		}
+	}
+
+	return apierror.Validation(fmt.Sprintf("%d fields are invalid", len(fields)), fields)
+}
+
+// message what is wrong with a field in words
+func message(fieldErr validator.FieldError) string {
+	field := fieldErr.Field()
+	param := fieldErr.Param()
+
+	switch fieldErr.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "phone":
+		return field + " must be a valid phone number"
+	case "oneof":
+		return field + " must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "gt":
+		return field + " must be greater than " + param
+	case "gte":
+		return field + " must be at least " + param
+	case "min":
+		switch fieldErr.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return fmt.Sprintf("%s must have at least %s item(s)", field, param)
+		case reflect.String:
+			return fmt.Sprintf("%s must be at least %s characters", field, param)
+		}
+		return field + " must be at least " + param
+	case "after":
+		return field + " must be after " + lowerFirst(param)
+	case "notbefore":
+		return field + " must not be before " + lowerFirst(param)
+	}
+
+	return field + " is invalid"
+}
+
+// lowerFirst the json name of a field named in a tag parameter, e.g. StartDate is startDate
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func isPhone(fl validator.FieldLevel) bool {
+	phone := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, fl.Field().String())
+
+	return phonePattern.MatchString(phone)
+}
+
+// compareTo a rule comparing a date with the sibling date named by the tag's parameter. either date missing passes,
+// a missing start or end is left to required, or means the range is open on that side
+func compareTo(ok func(t, other time.Time) bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		t, isTime := fl.Field().Interface().(time.Time)
+		if !isTime {
+			return false
+		}
+
+		parent := fl.Parent()
+		for parent.Kind() == reflect.Pointer {
+			parent = parent.Elem()
+		}
+
+		other := parent.FieldByName(fl.Param())
+		for other.Kind() == reflect.Pointer {
+			if other.IsNil() {
+				return true
+			}
+			other = other.Elem()
+		}
+
+		otherTime, isTime := other.Interface().(time.Time)
+		if !isTime {
+			return false
+		}
+
+		if t.IsZero() || otherTime.IsZero() {
+			return true
+		}
+
+		return ok(t, otherTime)
+	}
+}